@@ -15,6 +15,16 @@ const (
 	DIVIDE
 )
 
+var arithmeticSymbols = [...]string{"=", "+=", "-=", "*=", "/="}
+
+func (op arithmetic) String() string {
+	if int(op) < len(arithmeticSymbols) {
+		return arithmeticSymbols[op]
+	}
+
+	return fmt.Sprintf("arithmetic(%d)", uint8(op))
+}
+
 // Action represents a single action that an agent can perform to modify the world state.
 //
 // An action has preconditions (conditions) that must be met before it can be executed,
@@ -26,6 +36,12 @@ type Action struct {
 	repeatable bool
 	conditions Conditions
 	effects    Effects
+	executor   Executor
+	costFn     ActionCostFn
+	template   *actionTemplate  // Set on action templates only, see AddActionTemplate
+	param      any              // Value bound to the parameter of the template
+	composite  *compositeAction // Set on composite actions only, see AddCompositeAction
+	start      bool             // Set on the starting node of the plans only
 }
 
 // Actions is a collection of Action pointers.
@@ -51,6 +67,46 @@ func (actions *Actions) AddAction(name string, cost float32, repeatable bool, co
 	*actions = append(*actions, &action)
 }
 
+// ActionCostFn computes the cost of an action from the sensors, and the world state the action is performed in.
+type ActionCostFn func(sensors Sensors, view WorldView) float32
+
+// SetCostFn replaces the fixed cost of the action by a cost depending on the world state and the sensors,
+// e.g. a walking cost proportional to the distance. A negative cost is considered as zero.
+//
+// The forward search evaluates it against the world state simulated before the action, and the backward
+// search against the current world state. In the returned Plan, the actions with a cost function are
+// copies holding the cost evaluated for their step.
+func (action *Action) SetCostFn(costFn ActionCostFn) {
+	action.costFn = costFn
+}
+
+// GetCost returns the action's cost. In a Plan, it is the cost of this step.
+func (action *Action) GetCost() float32 {
+	return action.cost
+}
+
+// getCost returns the cost of the action performed in w.
+func (action *Action) getCost(w world) float32 {
+	if action.costFn == nil {
+		return action.cost
+	}
+
+	return max(action.costFn(w.Agent.sensors, WorldView{w: w}), 0)
+}
+
+// withCost returns the action with the given cost for a step of a Plan.
+// The action is copied only if its cost depends on the step.
+func (action *Action) withCost(cost float32) *Action {
+	if action.costFn == nil {
+		return action
+	}
+
+	step := *action
+	step.cost = cost
+
+	return &step
+}
+
 // GetName returns the action's name identifier.
 func (action *Action) GetName() string {
 	return action.name
@@ -90,52 +146,40 @@ func (effect Effect[T]) check(w world) bool {
 		return false
 	}
 
-	k := w.states.GetIndex(effect.Key)
-	if k < 0 {
-		return false
-	}
-	s := w.states[k]
-
-	if _, ok := s.(State[T]); !ok {
-		return false
-	}
+	value, ok := lookupState[T](&w, effect.Key)
 
-	return s.(State[T]).Value == effect.Value
+	return ok && value == effect.Value
 }
 
 func (effect Effect[T]) apply(w *world) error {
-	k := w.states.GetIndex(effect.Key)
-	if k < 0 {
+	value, ok := lookupState[T](w, effect.Key)
+	if !ok {
+		if w.hasState(effect.Key) {
+			return fmt.Errorf("%w: state %s", ErrTypeMismatch, w.schema().FormatKey(effect.Key))
+		}
+
 		if slices.Contains([]arithmetic{SET, ADD}, effect.Operator) {
-			w.states = append(w.states, State[T]{Key: effect.Key, Value: effect.Value})
-			return nil
+			return storeState(w, effect.Key, effect.Value)
 		} else if slices.Contains([]arithmetic{SUBSTRACT}, effect.Operator) {
-			w.states = append(w.states, State[T]{Key: effect.Key, Value: -effect.Value})
-			return nil
+			return storeState(w, effect.Key, -effect.Value)
 		}
 		return fmt.Errorf("w does not exist")
 	}
-	if _, ok := w.states[k].(State[T]); !ok {
-		return fmt.Errorf("type does not match")
-	}
 
-	state := w.states[k].(State[T])
 	switch effect.Operator {
 	case SET:
-		state.Value = effect.Value
+		value = effect.Value
 	case ADD:
-		state.Value += effect.Value
+		value += effect.Value
 	case SUBSTRACT:
-		state.Value -= effect.Value
+		value -= effect.Value
 	case MULTIPLY:
-		state.Value *= effect.Value
+		value *= effect.Value
 	case DIVIDE:
-		state.Value /= effect.Value
+		value /= effect.Value
 	}
 
-	state.Store(w)
-
-	return nil
+	return storeState(w, effect.Key, value)
 }
 
 // EffectBool represents a boolean state modification.
@@ -159,17 +203,9 @@ func (effectBool EffectBool) check(w world) bool {
 		return false
 	}
 
-	k := w.states.GetIndex(effectBool.Key)
-	if k < 0 {
-		return false
-	}
-	if _, ok := w.states[k].(State[bool]); !ok {
-		return false
-	}
-
-	s := w.states[k].(State[bool])
+	value, ok := lookupState[bool](&w, effectBool.Key)
 
-	return s.Value == effectBool.Value
+	return ok && value == effectBool.Value
 }
 
 func (effectBool EffectBool) apply(w *world) error {
@@ -177,21 +213,11 @@ func (effectBool EffectBool) apply(w *world) error {
 		return fmt.Errorf("operation %v not allowed on bool type", effectBool.Operator)
 	}
 
-	k := w.states.GetIndex(effectBool.Key)
-	if k < 0 {
-		w.states = append(w.states, State[bool]{Key: effectBool.Key, Value: effectBool.Value})
-		return nil
-	}
-	if _, ok := w.states[k].(State[bool]); !ok {
-		return fmt.Errorf("type does not match")
+	if _, ok := lookupState[bool](w, effectBool.Key); !ok && w.hasState(effectBool.Key) {
+		return fmt.Errorf("%w: state %s", ErrTypeMismatch, w.schema().FormatKey(effectBool.Key))
 	}
 
-	state := w.states[k].(State[bool])
-	state.Value = effectBool.Value
-
-	state.Store(w)
-
-	return nil
+	return storeState(w, effectBool.Key, effectBool.Value)
 }
 
 // EffectString represents a string state modification.
@@ -210,17 +236,9 @@ func (effectString EffectString) GetKey() StateKey {
 }
 
 func (effectString EffectString) check(w world) bool {
-	k := w.states.GetIndex(effectString.Key)
-	if k < 0 {
-		return false
-	}
-	if _, ok := w.states[k].(State[string]); !ok {
-		return false
-	}
+	value, ok := lookupState[string](&w, effectString.Key)
 
-	s := w.states[k].(State[string])
-
-	return s.Value == effectString.Value
+	return ok && value == effectString.Value
 }
 
 func (effectString EffectString) apply(w *world) error {
@@ -228,31 +246,60 @@ func (effectString EffectString) apply(w *world) error {
 		return fmt.Errorf("arithmetic operation %v not allowed on string type", effectString.Operator)
 	}
 
-	k := w.states.GetIndex(effectString.Key)
-	if k < 0 {
-		w.states = append(w.states, State[string]{Key: effectString.Key, Value: effectString.Value})
-		return nil
-	}
-	if _, ok := w.states[k].(State[string]); !ok {
-		return fmt.Errorf("type does not match")
+	value, ok := lookupState[string](w, effectString.Key)
+	if !ok {
+		if w.hasState(effectString.Key) {
+			return fmt.Errorf("%w: state %s", ErrTypeMismatch, w.schema().FormatKey(effectString.Key))
+		}
+		return storeState(w, effectString.Key, effectString.Value)
 	}
 
-	state := w.states[k].(State[string])
 	switch effectString.Operator {
 	case SET:
-		state.Value = effectString.Value
+		value = effectString.Value
 	case ADD:
-		state.Value = fmt.Sprint(state.Value, effectString.Value)
+		value = fmt.Sprint(value, effectString.Value)
 	}
 
-	state.Store(w)
+	return storeState(w, effectString.Key, value)
+}
 
-	return nil
+// EffectFn represents a procedural state modification.
+//
+// It allows for effects that cannot be expressed through simple state modifications, like
+// "the distance to the target becomes 0" or "the inventory gains the content of the chest sensor".
+// ApplyFn receives the sensors, that must not be modified, and a WorldEditor on the simulated world.
+//
+// An EffectFn is always considered to have an impact on the world state. With the backward search,
+// its Key is the only state considered as modified, and the actions modifying a state required by
+// the goal through an EffectFn are skipped.
+//
+// Example:
+//
+//	effect := EffectFn{
+//	    Key: ATTRIBUTE_WOOD,
+//	    ApplyFn: func(sensors Sensors, editor WorldEditor) error {
+//	        wood, _ := GetEditorState[int](editor, ATTRIBUTE_WOOD)
+//	        return SetEditorState(editor, ATTRIBUTE_WOOD, wood+sensors.GetSensor("chest").(int))
+//	    },
+//	}
+type EffectFn struct {
+	Key     StateKey                                        // Main state key modified
+	ApplyFn func(sensors Sensors, editor WorldEditor) error // Function that modifies the simulated world
+}
+
+// GetKey returns the main state key that this effect modifies.
+func (effectFn EffectFn) GetKey() StateKey {
+	return effectFn.Key
 }
 
-// EffectFn is a function type for custom procedural effects that directly modify the agent.
-// This allows for effects that cannot be expressed through simple state modifications.
-type EffectFn func(agent *Agent)
+func (effectFn EffectFn) check(w world) bool {
+	return false
+}
+
+func (effectFn EffectFn) apply(w *world) error {
+	return effectFn.ApplyFn(w.Agent.sensors, WorldEditor{w: w})
+}
 
 // Effects is a collection of EffectInterface implementations that describe how
 // an action modifies the world state.
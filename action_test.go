@@ -165,3 +165,98 @@ func TestEffect_Check(t *testing.T) {
 		})
 	}
 }
+
+func TestAction_GetCost(t *testing.T) {
+	agent := CreateAgent(Goals{}, Actions{})
+	SetState[int](&agent, 1, 4)
+	SetSensor(&agent, "speed", 2)
+
+	actions := Actions{}
+	actions.AddAction("walk", 10.0, false, Conditions{}, Effects{})
+	action := actions.GetAction("walk")
+
+	if action.GetCost() != 10.0 || action.getCost(agent.w) != 10.0 {
+		t.Errorf("Expected fixed cost 10.0, got %f", action.getCost(agent.w))
+	}
+	if action.withCost(5.0) != action {
+		t.Error("Expected an action without cost function not to be copied")
+	}
+
+	action.SetCostFn(func(sensors Sensors, view WorldView) float32 {
+		distance, _ := GetViewState[int](view, 1)
+		return float32(distance) / float32(sensors.GetSensor("speed").(int))
+	})
+	if got := action.getCost(agent.w); got != 2.0 {
+		t.Errorf("Expected cost 2.0, got %f", got)
+	}
+
+	step := action.withCost(2.0)
+	if step == action || step.GetCost() != 2.0 || action.GetCost() != 10.0 {
+		t.Error("Expected a copy holding the step's cost")
+	}
+
+	SetState[int](&agent, 1, -4)
+	if got := action.getCost(agent.w); got != 0 {
+		t.Errorf("Expected negative cost to be zero, got %f", got)
+	}
+}
+
+func TestAction_CostFnPlan(t *testing.T) {
+	actions := Actions{}
+	// Attacking is expensive while the enemy is strong
+	actions.AddAction("attack", 1.0, false, Conditions{}, Effects{
+		EffectBool{Key: 2, Value: true, Operator: SET},
+	})
+	actions.GetAction("attack").SetCostFn(func(sensors Sensors, view WorldView) float32 {
+		strength, _ := GetViewState[int](view, 1)
+		return float32(strength)
+	})
+	actions.AddAction("weaken", 2.0, false, Conditions{}, Effects{
+		Effect[int]{Key: 1, Value: 1, Operator: SET},
+	})
+
+	goals := Goals{
+		"win": {
+			Conditions: Conditions{&ConditionBool{Key: 2, Value: true, Operator: EQUAL}},
+			PriorityFn: func(sensors Sensors) float32 {
+				return 1.0
+			},
+		},
+	}
+
+	agent := CreateAgent(goals, actions)
+	SetState[int](&agent, 1, 10)
+	SetState[bool](&agent, 2, false)
+
+	result, err := FindPlan(agent, PlanOptions{MaxDepth: 5})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.Plan) != 3 || result.Plan[1].name != "weaken" || result.Plan[2].name != "attack" {
+		t.Fatalf("Expected plan [weaken, attack], got %d actions", len(result.Plan))
+	}
+	if result.Plan[2].GetCost() != 1.0 || result.Plan.GetTotalCost() != 3.0 {
+		t.Errorf("Expected attack cost 1.0 and total cost 3.0, got %f and %f", result.Plan[2].GetCost(), result.Plan.GetTotalCost())
+	}
+
+	// The enemy is weak already
+	SetState[int](&agent, 1, 2)
+	result, err = FindPlan(agent, PlanOptions{MaxDepth: 5})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.Plan) != 2 || result.Plan.GetTotalCost() != 2.0 {
+		t.Errorf("Expected plan [attack] with cost 2.0, got %d actions with cost %f", len(result.Plan), result.Plan.GetTotalCost())
+	}
+
+	result, err = FindPlan(agent, PlanOptions{MaxDepth: 5, Direction: BACKWARD})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.Plan) != 2 || result.Plan.GetTotalCost() != 2.0 {
+		t.Errorf("Expected backward plan [attack] with cost 2.0, got %d actions with cost %f", len(result.Plan), result.Plan.GetTotalCost())
+	}
+	if actions.GetAction("attack").GetCost() != 1.0 {
+		t.Error("Expected the action definition not to be modified")
+	}
+}
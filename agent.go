@@ -52,6 +52,8 @@
 //	goalName, plan := goapai.GetPlan(agent, 10)
 package goapai
 
+import "errors"
+
 // Agent represents an AI agent that uses GOAP (Goal-Oriented Action Planning) to make decisions.
 //
 // An agent maintains a world state, a set of goals with priorities, available actions,
@@ -67,6 +69,7 @@ type Agent struct {
 type goalInterface struct {
 	Conditions []ConditionInterface
 	PriorityFn GoalPriorityFn
+	Task       *Task // HTN task planned instead of a GOAP plan, optional
 }
 
 // GoalName is a unique identifier for a goal.
@@ -98,8 +101,10 @@ func CreateAgent(goals Goals, actions Actions) Agent {
 // SetState adds or updates a state value in the agent's world state.
 //
 // State values can be numeric types (int, int8, uint8, uint64, float64), bool, or string.
-// Each state is identified by a unique StateKey. Multiple calls with the same key will
-// create duplicate states; this is generally not recommended.
+// Each state is identified by a unique StateKey. Setting an existing key replaces its value,
+// and the world's hash is updated incrementally. A key that is not registered in the agent's Schema
+// can be set with another type, while a registered key keeps its type: the value is then ignored,
+// use TrySetState to get the error.
 //
 // Example:
 //
@@ -107,10 +112,86 @@ func CreateAgent(goals Goals, actions Actions) Agent {
 //	SetState[bool](&agent, 2, true)    // Set state key 2 to boolean true
 //	SetState[string](&agent, 3, "foo") // Set state key 3 to string "foo"
 func SetState[T Numeric | bool | string](agent *Agent, key StateKey, value T) {
-	agent.w.states = append(agent.w.states, State[T]{
+	State[T]{
+		Key:   key,
+		Value: value,
+	}.Store(&agent.w)
+}
+
+// TrySetState sets a state value like SetState, and returns an error matching ErrTypeMismatch
+// if the key is registered in the agent's Schema with another type than T.
+//
+// Example:
+//
+//	if err := TrySetState[float64](&agent, ATTRIBUTE_HEALTH, 80); err != nil {
+//	    log.Fatal(err)
+//	}
+func TrySetState[T Numeric | bool | string](agent *Agent, key StateKey, value T) error {
+	return State[T]{
 		Key:   key,
 		Value: value,
-	})
+	}.Store(&agent.w)
+}
+
+// GetState returns the value of a state in the agent's world state.
+// The boolean is false if the state does not exist, or if its type is not T.
+//
+// Example:
+//
+//	health, ok := GetState[int](&agent, 1)
+func GetState[T Numeric | bool | string](agent *Agent, key StateKey) (T, bool) {
+	return lookupState[T](&agent.w, key)
+}
+
+// SetStates adds or updates several states at once in the agent's world state.
+// As with SetState, the states whose key is registered in the agent's Schema with another type are ignored.
+//
+// Example:
+//
+//	agent.SetStates(State[int]{Key: 1, Value: 100}, State[bool]{Key: 2, Value: true})
+func (agent *Agent) SetStates(states ...StateInterface) {
+	for _, state := range states {
+		state.Store(&agent.w)
+	}
+}
+
+// TrySetStates sets several states like SetStates, and returns the type mismatches joined in an
+// error matching ErrTypeMismatch. The other states are set.
+func (agent *Agent) TrySetStates(states ...StateInterface) error {
+	var errs []error
+	for _, state := range states {
+		if err := state.Store(&agent.w); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// GetStateValue returns the untyped value of a state, and false if it does not exist.
+func (agent *Agent) GetStateValue(key StateKey) (any, bool) {
+	state, ok := agent.w.getState(key)
+	if !ok {
+		return nil, false
+	}
+
+	return state.GetValue(), true
+}
+
+// HasState returns true if the state exists in the agent's world state.
+func (agent *Agent) HasState(key StateKey) bool {
+	return agent.w.hasState(key)
+}
+
+// DeleteState removes a state from the agent's world state.
+// It returns false if the state does not exist.
+func (agent *Agent) DeleteState(key StateKey) bool {
+	return agent.w.deleteState(key)
+}
+
+// GetStateKeys returns the keys of all the states in the agent's world state.
+func (agent *Agent) GetStateKeys() []StateKey {
+	return agent.w.getKeys()
 }
 
 // SetSensor adds or updates a sensor value for the agent.
@@ -126,3 +207,15 @@ func SetState[T Numeric | bool | string](agent *Agent, key StateKey, value T) {
 func SetSensor[T Sensor](agent *Agent, name string, value T) {
 	agent.sensors[name] = value
 }
+
+// planningWorld returns a copy of the agent's world state for a planning request,
+// owning the cache of the ConditionFn.
+func (agent *Agent) planningWorld() world {
+	w := agent.w.clone()
+	w.conditionFns = conditionFnCache{}
+	for _, state := range w.states {
+		state.Store(&w)
+	}
+
+	return w
+}
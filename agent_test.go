@@ -188,3 +188,94 @@ func TestSetSensor(t *testing.T) {
 		})
 	}
 }
+
+func TestSetState_Upsert(t *testing.T) {
+	agent := CreateAgent(Goals{}, Actions{})
+	SetState[int](&agent, 1, 42)
+	SetState[bool](&agent, 2, true)
+	hash := agent.w.hash
+
+	SetState[int](&agent, 1, 100)
+	if len(agent.w.states) != 2 {
+		t.Fatalf("Expected 2 states, got %d", len(agent.w.states))
+	}
+	if value, _ := GetState[int](&agent, 1); value != 100 {
+		t.Errorf("Expected value 100, got %d", value)
+	}
+	if agent.w.hash == hash {
+		t.Error("Expected world hash to change")
+	}
+
+	// Setting back the original value restores the original hash
+	SetState[int](&agent, 1, 42)
+	if agent.w.hash != hash {
+		t.Errorf("Expected world hash %d, got %d", hash, agent.w.hash)
+	}
+
+	// Changing the type of a state replaces it
+	SetState[string](&agent, 1, "test")
+	if len(agent.w.states) != 2 {
+		t.Fatalf("Expected 2 states, got %d", len(agent.w.states))
+	}
+	if _, ok := GetState[int](&agent, 1); ok {
+		t.Error("Expected int state to be replaced")
+	}
+}
+
+func TestGetState(t *testing.T) {
+	agent := CreateAgent(Goals{}, Actions{})
+	SetState[int](&agent, 1, 42)
+
+	if value, ok := GetState[int](&agent, 1); !ok || value != 42 {
+		t.Errorf("GetState() = %v, %v, want 42, true", value, ok)
+	}
+	if _, ok := GetState[bool](&agent, 1); ok {
+		t.Error("Expected GetState with the wrong type to fail")
+	}
+	if _, ok := GetState[int](&agent, 2); ok {
+		t.Error("Expected GetState on a missing key to fail")
+	}
+}
+
+func TestAgent_StatesAPI(t *testing.T) {
+	agent := CreateAgent(Goals{}, Actions{})
+	agent.SetStates(
+		State[int]{Key: 1, Value: 42},
+		State[bool]{Key: 2, Value: true},
+		State[string]{Key: 3, Value: "test"},
+	)
+
+	keys := agent.GetStateKeys()
+	if len(keys) != 3 || keys[0] != 1 || keys[1] != 2 || keys[2] != 3 {
+		t.Errorf("GetStateKeys() = %v, want [1 2 3]", keys)
+	}
+
+	if value, ok := agent.GetStateValue(3); !ok || value != "test" {
+		t.Errorf("GetStateValue() = %v, %v, want 'test', true", value, ok)
+	}
+	if _, ok := agent.GetStateValue(4); ok {
+		t.Error("Expected GetStateValue on a missing key to fail")
+	}
+
+	if !agent.HasState(2) {
+		t.Error("Expected state 2 to exist")
+	}
+
+	if !agent.DeleteState(2) {
+		t.Error("Expected DeleteState to succeed")
+	}
+	if agent.HasState(2) {
+		t.Error("Expected state 2 to be deleted")
+	}
+	if agent.DeleteState(2) {
+		t.Error("Expected DeleteState on a missing key to fail")
+	}
+
+	// The hash must match a world built without the deleted state
+	expected := CreateAgent(Goals{}, Actions{})
+	SetState[int](&expected, 1, 42)
+	SetState[string](&expected, 3, "test")
+	if agent.w.hash != expected.w.hash {
+		t.Errorf("Expected world hash %d, got %d", expected.w.hash, agent.w.hash)
+	}
+}
@@ -2,117 +2,252 @@ package goapai
 
 import (
 	"container/heap"
+	"fmt"
 	"slices"
 )
 
 type node struct {
 	*Action
-	world world
+	world      world
+	conditions Conditions // Remaining conditions, used by the backward search only
 
 	parentNode *node
 	cost       float32
 	totalCost  float32
 	heuristic  float32
 	depth      uint16
-	heapIndex  int  // Index in the heap, needed for heap.Fix
-	closed     bool // true = closed node, false = open node
+	heapIndex  int   // Index in the heap, needed for heap.Fix
+	closed     bool  // true = closed node, false = open node
+	traceID    int   // Index of the node in PlanOptions.Trace
+	sameHash   *node // Next node of the nodeIndex with the same world hash
 }
 
 func astar(from world, goal goalInterface, actions Actions, maxDepth int) Plan {
-	availableActions := getImpactingActions(from, actions)
+	plan, _, _ := astarSearch(from, goal, actions, PlanOptions{MaxDepth: maxDepth})
 
+	return plan
+}
+
+// nodeIndexCapacity is the initial capacity of the nodeIndex of a forward search, so that the
+// searches of the small domains do not spend their time growing it.
+const nodeIndexCapacity = 64
+
+// astarSearch runs the forward A* search, and returns the plan found along with the
+// search statistics. When no plan is found, the error describes why the search failed.
+func astarSearch(from world, goal goalInterface, actions Actions, options PlanOptions) (Plan, SearchStats, error) {
+	search := createForwardSearch(from, goal, actions, options)
+	for !search.expand() {
+	}
+
+	return search.result()
+}
+
+// searcher is a search that can be interrupted between two expansions, and resumed later.
+type searcher interface {
+	// expand expands the next node of the search, and returns true once the search is finished.
+	expand() bool
+	// result returns the outcome of a finished search.
+	result() (Plan, SearchStats, error)
+	// getStats returns the statistics of the search so far.
+	getStats() SearchStats
+}
+
+// forwardSearch holds the state of a forward A* search between two expansions.
+type forwardSearch struct {
+	from             world
+	goal             goalInterface
+	availableActions Actions
+	hasTemplates     bool
+	bindings         actionBindings // Actions bound from the templates during the search
+	options          PlanOptions
+
+	nodesHeap nodeHeap
+	nodes     nodeIndex // Open and closed nodes indexed by their world hash
+	bestNode  *node     // Node closest to the goal, returned as a partial plan if the search is aborted
+
+	stats        SearchStats
+	applyErr     error
+	depthReached bool
+
+	done bool
+	plan Plan
+	err  error
+}
+
+func createForwardSearch(from world, goal goalInterface, actions Actions, options PlanOptions) *forwardSearch {
 	startNode := &node{
-		Action: &Action{},
-		world: world{
-			Agent:  from.Agent,
-			states: slices.Clone(from.states),
-			hash:   from.hash,
-		},
+		Action:     &Action{start: true},
+		world:      from.clone(),
+		heuristic:  computeHeuristic(from, goal, from),
 		parentNode: nil,
 		heapIndex:  -1,
 		closed:     false,
 	}
 
-	nodesHeap := nodeHeap{}
-	heap.Init(&nodesHeap)
-	heap.Push(&nodesHeap, startNode)
+	search := &forwardSearch{
+		from:             from,
+		goal:             goal,
+		availableActions: getImpactingActions(from, actions),
+		hasTemplates:     actions.hasTemplates(),
+		bindings:         actionBindings{},
+		options:          options,
+		nodesHeap:        nodeHeap{},
+		nodes:            make(nodeIndex, nodeIndexCapacity),
+		bestNode:         startNode,
+	}
+
+	heap.Init(&search.nodesHeap)
+	heap.Push(&search.nodesHeap, startNode)
+	search.nodes.add(startNode)
+	options.Trace.addNode(startNode)
+	search.stats.MaxOpenSet = 1
+
+	return search
+}
+
+func (search *forwardSearch) expand() bool {
+	if search.done {
+		return true
+	}
+
+	if search.nodesHeap.Len() == 0 {
+		switch {
+		case search.applyErr != nil:
+			return search.finish(Plan{}, search.applyErr)
+		case search.depthReached:
+			return search.finish(Plan{}, ErrMaxDepth)
+		default:
+			return search.finish(Plan{}, ErrGoalUnreachable)
+		}
+	}
+
+	if err := search.options.checkBudget(search.stats); err != nil {
+		return search.finish(buildPartialPlan(search.bestNode, search.options), err)
+	}
+
+	maxDepth := search.options.MaxDepth
+	parentNode := heap.Pop(&search.nodesHeap).(*node)
 
-	for nodesHeap.Len() > 0 {
-		parentNode := heap.Pop(&nodesHeap).(*node)
+	// A node beyond the depth limit is not expanded, so it stays open to a shallower path
+	if parentNode.depth > uint16(maxDepth) {
+		search.depthReached = true
+		search.options.Trace.setStatus(parentNode, REJECTED_DEPTH)
+		return false
+	}
+	parentNode.closed = true
 
-		if parentNode.depth > uint16(maxDepth) {
-			parentNode.closed = true
-			heap.Fix(&nodesHeap, parentNode.heapIndex)
+	// Simulate world state, and check if we are at current state
+	if countMissingGoal(search.goal, parentNode.world) == 0 {
+		search.options.Trace.setStatus(parentNode, NODE_GOAL)
+		return search.finish(buildPlanFromNode(parentNode), nil)
+	}
+	search.stats.NodesExpanded++
+	search.options.Trace.setStatus(parentNode, NODE_CLOSED)
+
+	actions := search.availableActions
+	if search.hasTemplates {
+		actions = bindActions(parentNode.world, actions, search.bindings)
+	}
+
+	trace := search.options.Trace
+	for _, action := range actions {
+		if !allowedRepetition(action, parentNode) {
+			trace.reject(parentNode, action, REJECTED_REPETITION, "")
 			continue
 		}
 
-		// Simulate world state, and check if we are at current state
-		if countMissingGoal(goal, parentNode.world) == 0 {
-			return buildPlanFromNode(parentNode)
+		if !action.conditions.Check(parentNode.world) {
+			trace.rejectConditions(parentNode, action)
+			continue
 		}
 
-		for _, action := range availableActions {
-			if !allowedRepetition(action, parentNode) {
-				continue
+		simulatedStates, ok, err := simulateActionState(action, parentNode.world)
+		if err != nil && search.applyErr == nil {
+			search.applyErr = fmt.Errorf("action %q: %w", action.name, err)
+		}
+		if !ok {
+			if err != nil {
+				trace.reject(parentNode, action, REJECTED_EFFECT_ERROR, err.Error())
+			} else {
+				trace.reject(parentNode, action, REJECTED_NO_EFFECT, "")
 			}
+			continue
+		}
 
-			if !action.conditions.Check(parentNode.world) {
-				continue
+		cost := action.getCost(parentNode.world)
+		currentNode, found := search.nodes.fetch(simulatedStates)
+		if found {
+			// Each world state is expanded at most once, closed nodes are never reopened.
+			// A node beyond the depth limit takes a shallower path within it, even if more expensive.
+			shallower := currentNode.depth > uint16(maxDepth) && parentNode.depth < uint16(maxDepth)
+			if !currentNode.closed && ((parentNode.cost+cost) < currentNode.cost || shallower) {
+				currentNode.Action = action
+				currentNode.world = simulatedStates
+				currentNode.parentNode = parentNode
+				currentNode.cost = parentNode.cost + cost
+				currentNode.totalCost = parentNode.cost + cost + currentNode.heuristic
+				currentNode.depth = parentNode.depth + 1
+
+				// Fix heap position after cost update, or push back the node rejected by the depth limit
+				if currentNode.heapIndex < 0 {
+					heap.Push(&search.nodesHeap, currentNode)
+					trace.setStatus(currentNode, NODE_OPEN)
+				} else {
+					heap.Fix(&search.nodesHeap, currentNode.heapIndex)
+				}
+				trace.updateNode(currentNode)
+			} else if trace != nil && currentNode.closed {
+				trace.reject(parentNode, action, REJECTED_DUPLICATE, fmt.Sprintf("same world state as node %d, already expanded", currentNode.traceID))
+			} else if trace != nil {
+				trace.reject(parentNode, action, REJECTED_DUPLICATE, fmt.Sprintf("same world state as node %d, with no better cost", currentNode.traceID))
 			}
-
-			simulatedStates, ok := simulateActionState(action, parentNode.world)
-			if !ok {
-				continue
+		} else {
+			// New node
+			heuristic := computeHeuristic(search.from, search.goal, simulatedStates)
+			newNode := &node{
+				Action:     action,
+				world:      simulatedStates,
+				parentNode: parentNode,
+				cost:       parentNode.cost + cost,
+				totalCost:  parentNode.cost + cost + heuristic,
+				heuristic:  heuristic,
+				depth:      parentNode.depth + 1,
+				heapIndex:  -1,
+				closed:     false,
 			}
-
-			currentNode, found := fetchNodeInHeap(nodesHeap, simulatedStates)
-			// Check if node exists in open nodes (closed=false)
-			if found && !currentNode.closed {
-				if (parentNode.cost + action.cost) < currentNode.cost {
-					currentNode.Action = action
-					currentNode.world = simulatedStates
-					currentNode.parentNode = parentNode
-					currentNode.cost = parentNode.cost + action.cost
-					currentNode.totalCost = parentNode.cost + action.cost + currentNode.heuristic
-					currentNode.depth = parentNode.depth + 1
-
-					// Fix heap position after cost update
-					heap.Fix(&nodesHeap, currentNode.heapIndex)
-				}
-			} else if found && currentNode.closed {
-				// Node was closed, reopen it with better cost
-				if (parentNode.cost + action.cost) < currentNode.cost {
-					currentNode.Action = action
-					currentNode.world = simulatedStates
-					currentNode.parentNode = parentNode
-					currentNode.cost = parentNode.cost + action.cost
-					currentNode.totalCost = parentNode.cost + action.cost + currentNode.heuristic
-					currentNode.depth = parentNode.depth + 1
-					currentNode.closed = false // Reopen
-
-					// Fix heap position
-					heap.Fix(&nodesHeap, currentNode.heapIndex)
-				}
-			} else {
-				// New node
-				heuristic := computeHeuristic(from, goal, simulatedStates)
-				newNode := &node{
-					Action:     action,
-					world:      simulatedStates,
-					parentNode: parentNode,
-					cost:       parentNode.cost + action.cost,
-					totalCost:  parentNode.cost + action.cost + heuristic,
-					heuristic:  heuristic,
-					depth:      parentNode.depth + 1,
-					heapIndex:  -1,
-					closed:     false,
-				}
-				heap.Push(&nodesHeap, newNode)
+			heap.Push(&search.nodesHeap, newNode)
+			search.nodes.add(newNode)
+			trace.addNode(newNode)
+			if newNode.depth <= uint16(maxDepth) && newNode.isCloserThan(search.bestNode) {
+				search.bestNode = newNode
 			}
+			search.stats.NodesGenerated++
+			search.stats.MaxOpenSet = max(search.stats.MaxOpenSet, search.nodesHeap.Len())
 		}
 	}
 
-	return Plan{}
+	return false
+}
+
+// finish ends the search with its outcome, and releases the nodes.
+func (search *forwardSearch) finish(plan Plan, err error) bool {
+	search.done = true
+	search.plan = plan
+	search.err = err
+	search.nodesHeap = nil
+	search.nodes = nil
+	search.bestNode = nil
+	search.bindings = nil
+
+	return true
+}
+
+func (search *forwardSearch) result() (Plan, SearchStats, error) {
+	return search.plan, search.stats, search.err
+}
+
+func (search *forwardSearch) getStats() SearchStats {
+	return search.stats
 }
 
 // All the actions similar to initial world are useless:
@@ -121,7 +256,7 @@ func getImpactingActions(from world, actions Actions) Actions {
 	var availableActions Actions
 
 	for _, action := range actions {
-		if !action.effects.satisfyStates(from) {
+		if action.template != nil || !action.effects.satisfyStates(from) {
 			availableActions = append(availableActions, action)
 		}
 	}
@@ -129,20 +264,56 @@ func getImpactingActions(from world, actions Actions) Actions {
 	return availableActions
 }
 
-func fetchNodeInHeap(heap nodeHeap, w world) (*node, bool) {
-	for _, n := range heap {
-		if n.world.Check(w) {
+// nodeIndex references the open and closed nodes by their world hash.
+// The nodes sharing the same hash in case of collision are chained through node.sameHash,
+// so that adding a node does not allocate once the map has grown.
+type nodeIndex map[uint64]*node
+
+func (index nodeIndex) add(n *node) {
+	n.sameHash = index[n.world.hash]
+	index[n.world.hash] = n
+}
+
+// fetch returns the node with a world state identical to w.
+func (index nodeIndex) fetch(w world) (*node, bool) {
+	for n := index[w.hash]; n != nil; n = n.sameHash {
+		if n.world.equal(w) {
 			return n, true
 		}
 	}
+
 	return nil, false
 }
 
+// isCloserThan returns true if n is closer to the goal than other, according to the
+// heuristic first, then to the cost.
+func (n *node) isCloserThan(other *node) bool {
+	if n.heuristic != other.heuristic {
+		return n.heuristic < other.heuristic
+	}
+
+	return n.cost < other.cost
+}
+
+// buildPartialPlan returns the plan leading to the best node, if partial plans are enabled by options.
+// The plan is empty if the best node is the starting node.
+func buildPartialPlan(best *node, options PlanOptions) Plan {
+	if !options.PartialPlan || best.depth == 0 {
+		return Plan{}
+	}
+
+	return buildPlanFromNode(best)
+}
+
 func buildPlanFromNode(node *node) Plan {
 	plan := make(Plan, 0, node.depth)
 
 	for node != nil {
-		plan = append(plan, node.Action)
+		if node.parentNode != nil {
+			plan = append(plan, node.Action.withCost(node.cost-node.parentNode.cost))
+		} else {
+			plan = append(plan, node.Action)
+		}
 		node = node.parentNode
 	}
 
@@ -151,20 +322,22 @@ func buildPlanFromNode(node *node) Plan {
 	return plan
 }
 
-func simulateActionState(action *Action, w world) (world, bool) {
+// simulateActionState returns the world resulting from the action's effects.
+// The boolean is false if the action has no impact on w, or if an effect could not be applied.
+func simulateActionState(action *Action, w world) (world, bool, error) {
 	/* If action effects implies no changes to current worldState,
 	then avoid generating huge chunks of memory */
 	if action.effects.satisfyStates(w) {
-		return world{}, false
+		return world{}, false, nil
 	}
 
-	w.states = slices.Clone(w.states)
+	w = w.clone()
 	err := action.effects.apply(&w)
 	if err != nil {
-		return world{}, false
+		return world{}, false, err
 	}
 
-	return w, true
+	return w, true, nil
 }
 
 func allowedRepetition(action *Action, parentNode *node) bool {
@@ -205,22 +378,28 @@ func computeHeuristic(from world, goal goalInterface, w world) float32 {
 	var totalDistance float32
 
 	for _, condition := range goal.Conditions {
-		key := condition.GetKey()
-		stateIndex := w.states.GetIndex(key)
-
-		if stateIndex >= 0 {
-			// State exists, calculate actual distance
-			state := w.states[stateIndex]
-			distance := state.Distance(condition)
-			totalDistance += distance
-		} else {
-			// State doesn't exist, use pessimistic estimate
-			// If the condition is not satisfied and state doesn't exist, assume distance of 1
-			if !condition.Check(w) {
-				totalDistance += 1.0
-			}
-		}
+		totalDistance += conditionDistance(condition, w)
 	}
 
 	return totalDistance
 }
+
+// conditionDistance returns the numeric distance between the state in w and the condition.
+func conditionDistance(condition ConditionInterface, w world) float32 {
+	if distanceCondition, ok := condition.(distanceCondition); ok {
+		if distance, found := distanceCondition.distance(w); found {
+			return distance
+		}
+	} else if state, found := w.getState(condition.GetKey()); found {
+		// State exists, calculate actual distance
+		return state.Distance(condition)
+	}
+
+	// State doesn't exist, use pessimistic estimate
+	// If the condition is not satisfied and state doesn't exist, assume distance of 1
+	if !condition.Check(w) {
+		return 1.0
+	}
+
+	return 0.0
+}
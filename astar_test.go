@@ -1,6 +1,7 @@
 package goapai
 
 import (
+	"errors"
 	"slices"
 	"testing"
 )
@@ -102,7 +103,10 @@ func TestSimulateActionState(t *testing.T) {
 		},
 	}
 
-	newStates, ok := simulateActionState(action, agent.w)
+	newStates, ok, err := simulateActionState(action, agent.w)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
 	if !ok {
 		t.Error("Expected simulation to succeed")
 	}
@@ -127,7 +131,7 @@ func TestSimulateActionState_NoChange(t *testing.T) {
 		},
 	}
 
-	_, ok := simulateActionState(action, agent.w)
+	_, ok, _ := simulateActionState(action, agent.w)
 	if ok {
 		t.Error("Expected simulation to fail when effects match current state")
 	}
@@ -466,3 +470,104 @@ func TestAstar_DataCloning(t *testing.T) {
 		t.Error("Original state values were modified")
 	}
 }
+
+// Test nodeIndex
+func TestNodeIndex_Fetch(t *testing.T) {
+	agent := CreateAgent(Goals{}, Actions{})
+	State[int]{Key: 1, Value: 100}.Store(&agent.w)
+	State[bool]{Key: 2, Value: true}.Store(&agent.w)
+
+	index := nodeIndex{}
+	index.add(&node{world: agent.w})
+
+	if _, found := index.fetch(agent.w); !found {
+		t.Error("Expected to find the identical world")
+	}
+
+	other := CreateAgent(Goals{}, Actions{})
+	State[int]{Key: 1, Value: 200}.Store(&other.w)
+	State[bool]{Key: 2, Value: true}.Store(&other.w)
+	if _, found := index.fetch(other.w); found {
+		t.Error("Expected not to find a different world")
+	}
+
+	// Same hash but different states (collision) must not match
+	collision := other.w
+	collision.hash = agent.w.hash
+	if _, found := index.fetch(collision); found {
+		t.Error("Expected hash collision not to match")
+	}
+}
+
+func TestAstar_ExpandsEachWorldOnce(t *testing.T) {
+	agent := CreateAgent(Goals{}, Actions{})
+	SetState[bool](&agent, 1, false)
+	SetState[bool](&agent, 2, false)
+	for _, state := range agent.w.states {
+		state.Store(&agent.w)
+	}
+
+	actions := Actions{}
+	actions.AddAction("set1", 1.0, false, Conditions{}, Effects{
+		EffectBool{Key: 1, Value: true, Operator: SET},
+	})
+	actions.AddAction("set2", 1.0, false, Conditions{}, Effects{
+		EffectBool{Key: 2, Value: true, Operator: SET},
+	})
+
+	goal := goalInterface{
+		Conditions: Conditions{
+			&ConditionBool{Key: 3, Value: true, Operator: EQUAL},
+		},
+	}
+
+	_, stats, err := astarSearch(agent.w, goal, actions, PlanOptions{MaxDepth: 10})
+	if !errors.Is(err, ErrGoalUnreachable) {
+		t.Errorf("Expected ErrGoalUnreachable, got %v", err)
+	}
+
+	// Worlds: start, {1}, {2}, {1, 2}; the last one is reached twice
+	if stats.NodesGenerated != 3 {
+		t.Errorf("Expected 3 generated nodes, got %d", stats.NodesGenerated)
+	}
+	if stats.NodesExpanded != 4 {
+		t.Errorf("Expected 4 expanded nodes, got %d", stats.NodesExpanded)
+	}
+}
+
+func TestAstar_ReopensDepthLimitedWorld(t *testing.T) {
+	agent := CreateAgent(Goals{}, Actions{})
+	SetState[int](&agent, 1, 0)
+
+	// The cheap path reaches the goal in 3 steps, the expensive one in 2
+	actions := Actions{}
+	actions.AddAction("a1", 1.0, false, Conditions{&Condition[int]{Key: 1, Value: 0, Operator: EQUAL}}, Effects{
+		Effect[int]{Key: 1, Value: 1, Operator: SET},
+	})
+	actions.AddAction("a2", 1.0, false, Conditions{&Condition[int]{Key: 1, Value: 1, Operator: EQUAL}}, Effects{
+		Effect[int]{Key: 1, Value: 2, Operator: SET},
+	})
+	actions.AddAction("a3", 1.0, false, Conditions{&Condition[int]{Key: 1, Value: 2, Operator: EQUAL}}, Effects{
+		Effect[int]{Key: 1, Value: 3, Operator: SET},
+	})
+	actions.AddAction("c", 5.0, false, Conditions{&Condition[int]{Key: 1, Value: 0, Operator: EQUAL}}, Effects{
+		Effect[int]{Key: 1, Value: 10, Operator: SET},
+	})
+	actions.AddAction("d", 5.0, false, Conditions{&Condition[int]{Key: 1, Value: 10, Operator: EQUAL}}, Effects{
+		Effect[int]{Key: 1, Value: 3, Operator: SET},
+	})
+
+	goal := goalInterface{
+		Conditions: Conditions{
+			&Condition[int]{Key: 1, Value: 3, Operator: EQUAL},
+		},
+	}
+
+	plan, _, err := astarSearch(agent.w, goal, actions, PlanOptions{MaxDepth: 2})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(plan) != 3 || plan[1].name != "c" || plan[2].name != "d" {
+		t.Errorf("Expected the plan [c d], got %v", plan)
+	}
+}
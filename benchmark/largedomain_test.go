@@ -0,0 +1,136 @@
+package benchmark
+
+import (
+	"fmt"
+	"goapai"
+	"testing"
+)
+
+const LARGE_DOMAIN_ACTIONS = 120
+
+// createLargeDomainAgent creates an agent with LARGE_DOMAIN_ACTIONS independent actions,
+// each one switching its own boolean state. The goal requires the first 5 states,
+// so that many orderings of the same actions lead to identical world states.
+// If dense is true, the states are registered in a Schema.
+func createLargeDomainAgent(dense bool) goapai.Agent {
+	actions := goapai.Actions{}
+	for i := 0; i < LARGE_DOMAIN_ACTIONS; i++ {
+		actions.AddAction(fmt.Sprintf("action%d", i), 1, false, goapai.Conditions{}, goapai.Effects{
+			goapai.EffectBool{Key: goapai.StateKey(i), Value: true, Operator: goapai.SET},
+		})
+	}
+
+	goalConditions := goapai.Conditions{}
+	for i := 0; i < 5; i++ {
+		goalConditions = append(goalConditions, &goapai.ConditionBool{Key: goapai.StateKey(i), Value: true, Operator: goapai.EQUAL})
+	}
+
+	goals := goapai.Goals{
+		"goal": {
+			Conditions: goalConditions,
+			PriorityFn: func(sensors goapai.Sensors) float32 {
+				return 1.0
+			},
+		},
+	}
+
+	agent := goapai.CreateAgent(goals, actions)
+	if dense {
+		schema := goapai.CreateSchema()
+		for i := 0; i < LARGE_DOMAIN_ACTIONS; i++ {
+			_ = goapai.RegisterState[bool](schema, goapai.StateKey(i))
+		}
+		var err error
+		if agent, err = goapai.CreateAgentWithSchema(goals, actions, schema); err != nil {
+			panic(err)
+		}
+	}
+	for i := 0; i < LARGE_DOMAIN_ACTIONS; i++ {
+		goapai.SetState[bool](&agent, goapai.StateKey(i), false)
+	}
+
+	return agent
+}
+
+func BenchmarkGoapAILargeDomain(b *testing.B) {
+	agent := createLargeDomainAgent(false)
+
+	for b.Loop() {
+		goapai.GetPlan(agent, 10)
+	}
+
+	b.ReportAllocs()
+}
+
+// createNumericDomainAgent creates an agent with 100 actions adding different amounts
+// to 10 numeric states, so that different sequences of actions reach the same sums.
+// If dense is true, the states are registered in a Schema.
+func createNumericDomainAgent(dense bool) goapai.Agent {
+	actions := goapai.Actions{}
+	for key := 0; key < 10; key++ {
+		for amount := 1; amount <= 10; amount++ {
+			actions.AddAction(fmt.Sprintf("add%d_%d", key, amount), float32(amount), false, goapai.Conditions{}, goapai.Effects{
+				goapai.Effect[int]{Key: goapai.StateKey(key), Value: amount, Operator: goapai.ADD},
+			})
+		}
+	}
+
+	goals := goapai.Goals{
+		"goal": {
+			Conditions: goapai.Conditions{
+				&goapai.Condition[int]{Key: 0, Value: 12, Operator: goapai.EQUAL},
+				&goapai.Condition[int]{Key: 1, Value: 12, Operator: goapai.EQUAL},
+			},
+			PriorityFn: func(sensors goapai.Sensors) float32 {
+				return 1.0
+			},
+		},
+	}
+
+	agent := goapai.CreateAgent(goals, actions)
+	if dense {
+		schema := goapai.CreateSchema()
+		for key := 0; key < 10; key++ {
+			_ = goapai.RegisterState[int](schema, goapai.StateKey(key))
+		}
+		var err error
+		if agent, err = goapai.CreateAgentWithSchema(goals, actions, schema); err != nil {
+			panic(err)
+		}
+	}
+	for key := 0; key < 10; key++ {
+		goapai.SetState[int](&agent, goapai.StateKey(key), 0)
+	}
+
+	return agent
+}
+
+func BenchmarkGoapAINumericDomain(b *testing.B) {
+	agent := createNumericDomainAgent(false)
+
+	for b.Loop() {
+		goapai.GetPlan(agent, 10)
+	}
+
+	b.ReportAllocs()
+}
+
+func BenchmarkGoapAILargeDomainDense(b *testing.B) {
+	agent := createLargeDomainAgent(true)
+
+	for b.Loop() {
+		goapai.GetPlan(agent, 10)
+	}
+
+	b.ReportAllocs()
+}
+
+func BenchmarkGoapAINumericDomainDense(b *testing.B) {
+	agent := createNumericDomainAgent(true)
+
+	for b.Loop() {
+		goapai.GetPlan(agent, 10)
+	}
+
+	b.ReportAllocs()
+}
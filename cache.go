@@ -0,0 +1,193 @@
+package goapai
+
+import (
+	"container/list"
+	"slices"
+	"sync"
+)
+
+// PlanCache is a bounded LRU cache of the plans found by the searches, enabled by PlanOptions.Cache.
+//
+// A plan is cached for a goal, the agent's actions and world state, the MaxDepth and the Direction
+// of the search: a request for the same goal of an agent sharing the same Actions, in the same world
+// state, returns the cached plan without any search. The goals are identified by their name and
+// definition, and the actions by the Actions slice: the agents created from the same Goals and Actions
+// share their cached plans, and adding, removing or replacing an action invalidates the plans cached
+// for the previous actions. Only the plans found are cached, not the failures nor the partial plans.
+//
+// The sensors are not part of the key: a cache must not be used for goals whose plans depend on the
+// sensors, through ConditionFn, cost functions or action templates. Call Invalidate once such an input,
+// or an action modified in place (e.g. with SetCostFn), changed.
+//
+// A PlanCache is safe for concurrent use, and can be shared by the requests of a PlannerPool.
+//
+// Example:
+//
+//	cache := goapai.CreatePlanCache(1024)
+//	for _, entity := range entities {
+//	    result, err := goapai.FindPlan(entity.agent, goapai.PlanOptions{MaxDepth: 10, Cache: cache})
+//	}
+//	fmt.Println(cache.Stats())
+type PlanCache struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[planCacheKey]*list.Element
+	order    *list.List // Entries by most recent use first
+	stats    PlanCacheStats
+}
+
+// PlanCacheStats holds the statistics of a PlanCache.
+type PlanCacheStats struct {
+	Hits      int // Number of requests answered by the cache
+	Misses    int // Number of requests searched, as no plan was cached
+	Evictions int // Number of plans removed to stay within the capacity
+	Size      int // Number of plans cached
+}
+
+type planCacheKey struct {
+	goalName   GoalName
+	goal       *ConditionInterface // Identity of the goal's conditions
+	task       *Task
+	actions    **Action // Identity of the agent's actions
+	hash       uint64
+	maxDepth   int
+	direction  direction
+	numActions int
+}
+
+type planCacheEntry struct {
+	key     planCacheKey
+	world   world   // World state of the request, compared in case of hash collision
+	actions Actions // Actions of the request, compared in case an action was replaced
+	plan    Plan
+}
+
+// CreatePlanCache creates a PlanCache holding at most capacity plans, at least 1.
+func CreatePlanCache(capacity int) *PlanCache {
+	return &PlanCache{
+		capacity: max(capacity, 1),
+		entries:  map[planCacheKey]*list.Element{},
+		order:    list.New(),
+	}
+}
+
+// Stats returns the statistics of the cache.
+func (cache *PlanCache) Stats() PlanCacheStats {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	stats := cache.stats
+	stats.Size = cache.order.Len()
+
+	return stats
+}
+
+// Invalidate removes all the cached plans. The statistics are kept.
+func (cache *PlanCache) Invalidate() {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	clear(cache.entries)
+	cache.order.Init()
+}
+
+// createPlanCacheKey returns the key of the plans of the goal from the world w.
+func createPlanCacheKey(w world, goalName GoalName, goal goalInterface, actions Actions, options PlanOptions) planCacheKey {
+	key := planCacheKey{
+		goalName:   goalName,
+		task:       goal.Task,
+		hash:       w.hash,
+		maxDepth:   options.MaxDepth,
+		direction:  options.Direction,
+		numActions: len(actions),
+	}
+	if len(goal.Conditions) > 0 {
+		key.goal = &goal.Conditions[0]
+	}
+	if len(actions) > 0 {
+		key.actions = &actions[0]
+	}
+
+	return key
+}
+
+// get returns the plan cached for the key, the world w and the actions, and counts the hit or the miss.
+func (cache *PlanCache) get(key planCacheKey, w world, actions Actions) (Plan, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if element, ok := cache.entries[key]; ok {
+		entry := element.Value.(*planCacheEntry)
+		if entry.world.equal(w) && slices.Equal(entry.actions, actions) {
+			cache.order.MoveToFront(element)
+			cache.stats.Hits++
+			return slices.Clone(entry.plan), true
+		}
+	}
+	cache.stats.Misses++
+
+	return nil, false
+}
+
+// add caches the plan for the key, the world w and the actions, evicting the least recently used plan if full.
+func (cache *PlanCache) add(key planCacheKey, w world, actions Actions, plan Plan) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	entry := &planCacheEntry{key: key, world: w.clone(), actions: slices.Clone(actions), plan: slices.Clone(plan)}
+	if element, ok := cache.entries[key]; ok {
+		element.Value = entry
+		cache.order.MoveToFront(element)
+		return
+	}
+
+	cache.entries[key] = cache.order.PushFront(entry)
+	if cache.order.Len() > cache.capacity {
+		oldest := cache.order.Back()
+		cache.order.Remove(oldest)
+		delete(cache.entries, oldest.Value.(*planCacheEntry).key)
+		cache.stats.Evictions++
+	}
+}
+
+// cachedSearch is a search answered by a PlanCache.
+type cachedSearch struct {
+	plan Plan
+}
+
+func (search cachedSearch) expand() bool {
+	return true
+}
+
+func (search cachedSearch) result() (Plan, SearchStats, error) {
+	return search.plan, SearchStats{}, nil
+}
+
+func (search cachedSearch) getStats() SearchStats {
+	return SearchStats{}
+}
+
+// cachingSearch adds the plan found by its search to a PlanCache.
+type cachingSearch struct {
+	searcher
+	cache   *PlanCache
+	key     planCacheKey
+	world   world
+	actions Actions
+	stored  bool
+}
+
+func (search *cachingSearch) expand() bool {
+	if !search.searcher.expand() {
+		return false
+	}
+
+	if !search.stored {
+		search.stored = true
+		if plan, _, err := search.searcher.result(); err == nil {
+			search.cache.add(search.key, search.world, search.actions, plan)
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,171 @@
+package goapai
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPlanCache(t *testing.T) {
+	agent := createTraceAgent(t)
+	cache := CreatePlanCache(2)
+	options := PlanOptions{MaxDepth: 5, Cache: cache}
+
+	result, err := FindPlan(agent, options)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.NodesExpanded == 0 {
+		t.Error("Expected the first request to be searched")
+	}
+
+	// The other agents share the schema of the agent
+	createAgent := func(goals Goals, actions Actions) Agent {
+		created, err := CreateAgentWithSchema(goals, actions, agent.w.schema())
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return created
+	}
+
+	// Another agent sharing the goals and actions, in the same world state
+	other := createAgent(agent.goals, agent.actions)
+	SetState[bool](&other, 1, false)
+	SetState[int](&other, 2, 0)
+
+	cached, err := FindPlan(other, options)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cached.NodesExpanded != 0 {
+		t.Errorf("Expected the plan to be cached, got %d nodes expanded", cached.NodesExpanded)
+	}
+	if names, expected := strings.Join(planNames(cached.Plan), ","), strings.Join(planNames(result.Plan), ","); names != expected {
+		t.Errorf("Expected the plan %s, got %s", expected, names)
+	}
+	if stats := cache.Stats(); stats != (PlanCacheStats{Hits: 1, Misses: 1, Size: 1}) {
+		t.Errorf("Expected 1 hit, 1 miss and 1 plan, got %+v", stats)
+	}
+
+	tests := []struct {
+		name  string
+		agent func() Agent
+	}{
+		{
+			name: "other world state",
+			agent: func() Agent {
+				changed := createAgent(agent.goals, agent.actions)
+				SetState[bool](&changed, 1, true)
+				SetState[int](&changed, 2, 0)
+				return changed
+			},
+		},
+		{
+			name: "action added",
+			agent: func() Agent {
+				actions := slices.Clone(agent.actions)
+				actions.AddAction("buy_wood", 5, true, Conditions{}, Effects{Effect[int]{Key: 2, Value: 2, Operator: ADD}})
+				changed := createAgent(agent.goals, actions)
+				changed.w = agent.w.clone()
+				return changed
+			},
+		},
+		{
+			name: "action replaced",
+			agent: func() Agent {
+				actions := slices.Clone(agent.actions)
+				changed := createAgent(agent.goals, actions)
+				changed.w = agent.w.clone()
+				if _, err := FindPlan(changed, options); err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				// The slice of the cached plan is the same, its content is not
+				actions[0] = &Action{name: "chop_wood", cost: 2, repeatable: true, conditions: actions[0].conditions, effects: actions[0].effects}
+				return changed
+			},
+		},
+		{
+			name: "other goal with the same name",
+			agent: func() Agent {
+				goals := Goals{"gather_wood": {
+					Conditions: Conditions{&Condition[int]{Key: 2, Value: 1, Operator: UPPER_OR_EQUAL}},
+					PriorityFn: agent.goals["gather_wood"].PriorityFn,
+				}}
+				changed := createAgent(goals, agent.actions)
+				changed.w = agent.w.clone()
+				return changed
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			changed := test.agent()
+			misses := cache.Stats().Misses
+
+			result, err := FindPlan(changed, options)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if result.NodesExpanded == 0 || cache.Stats().Misses != misses+1 {
+				t.Error("Expected the plan not to be cached")
+			}
+			if err := result.Plan.Validate(&changed); err != nil {
+				t.Errorf("Expected the plan to be valid, got %v", err)
+			}
+		})
+	}
+
+	// The capacity is 2: the first plan was evicted by the last requests
+	stats := cache.Stats()
+	if stats.Size != 2 || stats.Evictions != 3 {
+		t.Errorf("Expected 2 plans and 3 evictions, got %+v", stats)
+	}
+	if result, _ := FindPlan(agent, options); result.NodesExpanded == 0 {
+		t.Error("Expected the evicted plan to be searched again")
+	}
+
+	cache.Invalidate()
+	if stats := cache.Stats(); stats.Size != 0 {
+		t.Errorf("Expected no plan after Invalidate, got %d", stats.Size)
+	}
+	if result, _ := FindPlan(agent, options); result.NodesExpanded == 0 {
+		t.Error("Expected the plan to be searched after Invalidate")
+	}
+}
+
+func TestPlanCache_Failure(t *testing.T) {
+	agent := createTraceAgent(t)
+	cache := CreatePlanCache(4)
+
+	// A failure is not cached, and a plan found with another MaxDepth is not reused
+	for _, maxDepth := range []int{2, 2, 5} {
+		if _, err := FindPlan(agent, PlanOptions{MaxDepth: maxDepth, Cache: cache}); (err != nil) != (maxDepth == 2) {
+			t.Errorf("Unexpected error with MaxDepth %d: %v", maxDepth, err)
+		}
+	}
+	if stats := cache.Stats(); stats.Hits != 0 || stats.Misses != 3 || stats.Size != 1 {
+		t.Errorf("Expected 3 misses and 1 plan, got %+v", stats)
+	}
+}
+
+func TestPlanCache_Pool(t *testing.T) {
+	agent := createTraceAgent(t)
+	cache := CreatePlanCache(16)
+	pool := CreatePlannerPool(4)
+	defer pool.Close()
+
+	responses := make([]<-chan PlanResponse, 32)
+	for i := range responses {
+		responses[i] = pool.Submit(agent, PlanOptions{MaxDepth: 5, Cache: cache})
+	}
+	for _, response := range responses {
+		if response := <-response; response.Err != nil || len(response.Result.Plan) != 4 {
+			t.Errorf("Expected a plan of 3 actions, got %v, %v", planNames(response.Result.Plan), response.Err)
+		}
+	}
+
+	if stats := cache.Stats(); stats.Hits+stats.Misses != len(responses) || stats.Size != 1 {
+		t.Errorf("Expected %d requests and 1 plan, got %+v", len(responses), stats)
+	}
+}
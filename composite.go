@@ -0,0 +1,100 @@
+package goapai
+
+import (
+	"fmt"
+	"slices"
+)
+
+// compositeAction holds the refinement of a composite action into a sub-plan.
+type compositeAction struct {
+	children   Actions    // Fixed sequence of actions
+	subGoal    Conditions // Goal planned with subActions, when there are no children
+	subActions Actions
+}
+
+// AddCompositeAction creates an action performed through a fixed sequence of child actions,
+// and appends it to the Actions collection.
+//
+// The planner only considers the conditions, effects and cost of the composite action, that must
+// summarise its children: high-level plans are found with less expansions and a lower depth.
+// The children are only used at execution time, once the Runner reaches the composite action,
+// and can be composite actions themselves.
+func (actions *Actions) AddCompositeAction(name string, cost float32, repeatable bool, conditions Conditions, effects Effects, children Actions) {
+	*actions = append(*actions, &Action{
+		name:       name,
+		cost:       cost,
+		repeatable: repeatable,
+		conditions: conditions,
+		effects:    effects,
+		composite:  &compositeAction{children: children},
+	})
+}
+
+// AddRefinedAction creates an action performed through a sub-plan achieving subGoal with subActions,
+// and appends it to the Actions collection.
+//
+// Like AddCompositeAction, the planner only considers the conditions, effects and cost of the action.
+// The sub-plan is planned at execution time, from the world state reached by then, once the Runner
+// reaches the action.
+func (actions *Actions) AddRefinedAction(name string, cost float32, repeatable bool, conditions Conditions, effects Effects, subGoal Conditions, subActions Actions) {
+	*actions = append(*actions, &Action{
+		name:       name,
+		cost:       cost,
+		repeatable: repeatable,
+		conditions: conditions,
+		effects:    effects,
+		composite:  &compositeAction{subGoal: subGoal, subActions: subActions},
+	})
+}
+
+// IsComposite returns true if the action is created by AddCompositeAction or AddRefinedAction.
+func (action *Action) IsComposite() bool {
+	return action.composite != nil
+}
+
+// Refine returns the actions performing a composite action, from the agent's current world state.
+// The actions of the refinement can be composite actions themselves.
+//
+// For an action created by AddRefinedAction, the sub-goal is planned with options, in the same way
+// as FindPlan. The starting node is not part of the refinement, which is empty if the sub-goal
+// is already achieved. An action that is not composite is refined into itself.
+func (action *Action) Refine(agent *Agent, options PlanOptions) (Plan, error) {
+	if action.composite == nil {
+		return Plan{action}, nil
+	}
+
+	if action.composite.children != nil {
+		return Plan(slices.Clone(action.composite.children)), nil
+	}
+
+	w := agent.planningWorld()
+	goal := goalInterface{Conditions: action.composite.subGoal}
+
+	var search searcher
+	if options.Direction == BACKWARD {
+		search = createBackwardSearch(w, goal, action.composite.subActions, options)
+	} else {
+		search = createForwardSearch(w, goal, action.composite.subActions, options)
+	}
+	for !search.expand() {
+	}
+
+	plan, _, err := search.result()
+	if err != nil {
+		return Plan{}, fmt.Errorf("action %q: %w", action.name, err)
+	}
+
+	return plan.steps(), nil
+}
+
+// refine replaces the composite action of the current step by its refinement.
+func (runner *Runner) refine() error {
+	refinement, err := runner.plan[runner.step].Refine(runner.agent, runner.options)
+	if err != nil {
+		return err
+	}
+
+	runner.plan = slices.Concat(runner.plan[:runner.step], refinement, runner.plan[runner.step+1:])
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package goapai
+
+import (
+	"errors"
+	"testing"
+)
+
+// createWoodAgent creates an agent requiring 30 wood, chopped one at a time.
+// The composite action "chop_10" summarises 10 "chop" actions.
+func createWoodAgent() (*Agent, Actions) {
+	primitives := Actions{}
+	primitives.AddAction("chop", 1.0, true, Conditions{}, Effects{
+		Effect[int]{Key: 1, Value: 1, Operator: ADD},
+	})
+
+	children := Actions{}
+	for i := 0; i < 10; i++ {
+		children = append(children, primitives[0])
+	}
+
+	actions := Actions{}
+	actions.AddCompositeAction("chop_10", 10.0, true, Conditions{}, Effects{
+		Effect[int]{Key: 1, Value: 10, Operator: ADD},
+	}, children)
+
+	goals := Goals{
+		"get_wood": {
+			Conditions: Conditions{&Condition[int]{Key: 1, Value: 30, Operator: UPPER_OR_EQUAL}},
+			PriorityFn: func(sensors Sensors) float32 {
+				return 1.0
+			},
+		},
+	}
+
+	agent := CreateAgent(goals, actions)
+	SetState[int](&agent, 1, 0)
+
+	return &agent, primitives
+}
+
+func TestAddCompositeAction_Plan(t *testing.T) {
+	agent, primitives := createWoodAgent()
+
+	// The flat domain cannot reach the goal within the depth limit
+	flat := CreateAgent(agent.goals, primitives)
+	SetState[int](&flat, 1, 0)
+	if _, err := FindPlan(flat, PlanOptions{MaxDepth: 10}); !errors.Is(err, ErrMaxDepth) {
+		t.Fatalf("Expected ErrMaxDepth with primitive actions, got %v", err)
+	}
+
+	result, err := FindPlan(*agent, PlanOptions{MaxDepth: 10})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.Plan) != 4 || !result.Plan[1].IsComposite() {
+		t.Fatalf("Expected plan with 3 composite actions, got %d actions", len(result.Plan))
+	}
+
+	refinement, err := result.Plan[1].Refine(agent, PlanOptions{MaxDepth: 10})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(refinement) != 10 || refinement[0].GetName() != "chop" {
+		t.Errorf("Expected refinement with 10 'chop', got %d actions", len(refinement))
+	}
+}
+
+func TestAddRefinedAction_Refine(t *testing.T) {
+	subActions := Actions{}
+	subActions.AddAction("get_wood", 1.0, false, Conditions{}, Effects{
+		EffectBool{Key: 1, Value: true, Operator: SET},
+	})
+	subActions.AddAction("light", 1.0, false, Conditions{
+		&ConditionBool{Key: 1, Value: true, Operator: EQUAL},
+	}, Effects{
+		EffectBool{Key: 2, Value: true, Operator: SET},
+	})
+
+	actions := Actions{}
+	actions.AddRefinedAction("make_fire", 2.0, false, Conditions{}, Effects{
+		EffectBool{Key: 2, Value: true, Operator: SET},
+	}, Conditions{
+		&ConditionBool{Key: 2, Value: true, Operator: EQUAL},
+	}, subActions)
+	action := actions.GetAction("make_fire")
+
+	agent := CreateAgent(Goals{}, actions)
+	SetState[bool](&agent, 1, false)
+	SetState[bool](&agent, 2, false)
+
+	for _, direction := range []direction{FORWARD, BACKWARD} {
+		refinement, err := action.Refine(&agent, PlanOptions{MaxDepth: 10, Direction: direction})
+		if err != nil {
+			t.Fatalf("Direction %d: unexpected error: %v", direction, err)
+		}
+		if len(refinement) != 2 || refinement[0].GetName() != "get_wood" || refinement[1].GetName() != "light" {
+			t.Errorf("Direction %d: expected refinement [get_wood, light], got %d actions", direction, len(refinement))
+		}
+	}
+
+	// The refinement is planned from the current world state
+	SetState[bool](&agent, 1, true)
+	if refinement, _ := action.Refine(&agent, PlanOptions{MaxDepth: 10}); len(refinement) != 1 {
+		t.Errorf("Expected refinement [light], got %d actions", len(refinement))
+	}
+	SetState[bool](&agent, 2, true)
+	if refinement, _ := action.Refine(&agent, PlanOptions{MaxDepth: 10}); len(refinement) != 0 {
+		t.Errorf("Expected empty refinement, got %d actions", len(refinement))
+	}
+
+	SetState[bool](&agent, 1, false)
+	SetState[bool](&agent, 2, false)
+	if _, err := action.Refine(&agent, PlanOptions{MaxDepth: 1}); !errors.Is(err, ErrMaxDepth) {
+		t.Errorf("Expected ErrMaxDepth, got %v", err)
+	}
+
+	primitive := subActions.GetAction("light")
+	if refinement, err := primitive.Refine(&agent, PlanOptions{}); err != nil || len(refinement) != 1 || refinement[0] != primitive {
+		t.Error("Expected a primitive action to be refined into itself")
+	}
+}
+
+func TestRunner_Tick_Composite(t *testing.T) {
+	agent, _ := createWoodAgent()
+	runner := CreateRunner(agent, PlanOptions{MaxDepth: 10})
+
+	for i := 0; i < 15; i++ {
+		if err := runner.Tick(); err != nil {
+			t.Fatalf("Tick %d: unexpected error: %v", i, err)
+		}
+		if action := runner.GetPlan()[i+1]; action.GetName() != "chop" {
+			t.Fatalf("Tick %d: expected action 'chop' to be performed, got '%s'", i, action.GetName())
+		}
+	}
+
+	// The composite actions are refined lazily
+	plan := runner.GetPlan()
+	if len(plan) != 22 {
+		t.Errorf("Expected plan with 22 actions (root + 20 chop + 1 composite), got %d", len(plan))
+	}
+	if !plan[len(plan)-1].IsComposite() {
+		t.Error("Expected the last action to remain composite")
+	}
+}
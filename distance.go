@@ -72,6 +72,47 @@ func (state State[T]) Distance(condition ConditionInterface) float32 {
 	return 0
 }
 
+// distanceCondition is implemented by the conditions able to compute their own distance to
+// a world, whatever the layout of its states.
+type distanceCondition interface {
+	distance(w world) (float32, bool)
+}
+
+// distance returns the distance between the state in w and the condition's target value,
+// and false if the state does not exist or has another type.
+func (condition *Condition[T]) distance(w world) (float32, bool) {
+	value, ok := condition.lookup(w)
+	if !ok {
+		return 0, false
+	}
+
+	return calculateNumericDistance(float64(value), float64(condition.Value), condition.Operator), true
+}
+
+func (conditionBool *ConditionBool) distance(w world) (float32, bool) {
+	value, ok := lookupState[bool](&w, conditionBool.Key)
+	if !ok {
+		return 0, false
+	}
+	if conditionBool.compare(value) {
+		return 0, true
+	}
+
+	return 1, true
+}
+
+func (conditionString *ConditionString) distance(w world) (float32, bool) {
+	value, ok := lookupState[string](&w, conditionString.Key)
+	if !ok {
+		return 0, false
+	}
+	if conditionString.compare(value) {
+		return 0, true
+	}
+
+	return 1, true
+}
+
 // calculateNumericDistance computes the distance for numeric conditions based on operator
 func calculateNumericDistance(current, target float64, op operator) float32 {
 	switch op {
@@ -0,0 +1,759 @@
+package goapai
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+)
+
+// Domain holds the actions, goals and states declared in a domain file.
+//
+// The Actions and Goals can be shared by all the agents created from the domain, and extended
+// or modified by code before creating them (e.g. to attach an Executor or a cost function).
+type Domain struct {
+	Actions Actions
+	Goals   Goals
+	Schema  *Schema // Declared name and type of each state
+
+	states []StateInterface // Initial value of the states
+}
+
+// DomainError is returned when a domain file is invalid, with the line of the invalid value.
+// It matches ErrInvalidDomain with errors.Is.
+type DomainError struct {
+	File    string // Empty for a domain loaded by LoadDomain
+	Line    int
+	Message string
+}
+
+func (err *DomainError) Error() string {
+	if err.File == "" {
+		return fmt.Sprintf("line %d: %s", err.Line, err.Message)
+	}
+
+	return fmt.Sprintf("%s:%d: %s", err.File, err.Line, err.Message)
+}
+
+func (err *DomainError) Unwrap() error {
+	return ErrInvalidDomain
+}
+
+var (
+	domainStateKinds = map[string]stateKind{
+		"int8":    kindInt8,
+		"int":     kindInt,
+		"uint8":   kindUint8,
+		"uint64":  kindUint64,
+		"float64": kindFloat64,
+		"bool":    kindBool,
+		"string":  kindString,
+	}
+	domainOperators = map[string]operator{
+		"==": EQUAL,
+		"!=": NOT_EQUAL,
+		"<=": LOWER_OR_EQUAL,
+		"<":  LOWER,
+		">=": UPPER_OR_EQUAL,
+		">":  UPPER,
+	}
+	domainArithmetics = map[string]arithmetic{
+		"=":  SET,
+		"+=": ADD,
+		"-=": SUBSTRACT,
+		"*=": MULTIPLY,
+		"/=": DIVIDE,
+	}
+)
+
+// LoadDomainFile loads the domain file at path, see LoadDomain.
+// The errors of the file are reported with its path.
+func LoadDomainFile(path string) (*Domain, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return loadDomain(path, data)
+}
+
+// LoadDomain loads a domain declared in JSON, so that designers can tweak the actions and goals
+// without recompiling. JSON is the only format supported: a domain written in another format,
+// such as YAML, must be converted to JSON first.
+//
+// The states are declared with a name and a type (int8, int, uint8, uint64, float64, bool or string),
+// and optionally an initial value and a key. Without key, a state gets the key following the one
+// of the previous state, starting at 0. The conditions and effects reference the states by name, with
+// a relational operator (==, !=, <=, <, >=, >) or an arithmetic operator (=, +=, -=, *=, /=).
+// The priority of a goal is either a number, or an expression over the sensors (e.g. "hunger / 10").
+//
+// A priority expression combines numbers, true and false, sensor names, the arithmetic operators (+, -, *, /),
+// the relational operators, the logical operators (!, &&, ||), parentheses, and the min and max functions.
+// The sensors are read as numbers, true being 1: a missing sensor, or a sensor that is not a number nor
+// a boolean, is 0. The relational and logical operators return 1 or 0.
+//
+// Any invalid value (unknown state or field, type or operator mismatch, duplicated name) is reported as
+// a DomainError with its line.
+//
+// Example:
+//
+//	{
+//	    "states": [
+//	        {"name": "has_axe", "type": "bool", "value": true},
+//	        {"name": "wood", "type": "int", "value": 0}
+//	    ],
+//	    "actions": [
+//	        {
+//	            "name": "chop_wood", "cost": 2, "repeatable": true,
+//	            "conditions": [{"state": "has_axe", "operator": "==", "value": true}],
+//	            "effects": [{"state": "wood", "operator": "+=", "value": 1}]
+//	        }
+//	    ],
+//	    "goals": [
+//	        {"name": "gather_wood", "priority": "1 - wood_stock / 100", "conditions": [{"state": "wood", "operator": ">=", "value": 5}]}
+//	    ]
+//	}
+func LoadDomain(r io.Reader) (*Domain, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return loadDomain("", data)
+}
+
+// CreateAgent creates an agent with the actions and goals of the domain, storing its states in
+// the dense layout of the domain's Schema, and initialised with the declared values.
+func (domain *Domain) CreateAgent() Agent {
+	// The loader builds the conditions and effects with the declared types of the states
+	agent := createAgentWithSchema(domain.Goals, domain.Actions, domain.Schema)
+	agent.SetStates(domain.states...)
+
+	return agent
+}
+
+// GetStateKey returns the key of the state declared with name, and false if it is not declared.
+func (domain *Domain) GetStateKey(name string) (StateKey, bool) {
+	return domain.Schema.GetKey(name)
+}
+
+// domainLoader builds a Domain from the nodes of a domain file.
+type domainLoader struct {
+	file    string
+	domain  *Domain
+	kinds   map[StateKey]stateKind
+	nextKey StateKey // Key of the next state declared without key
+}
+
+func loadDomain(file string, data []byte) (*Domain, error) {
+	loader := &domainLoader{
+		file: file,
+		domain: &Domain{
+			Actions: Actions{},
+			Goals:   Goals{},
+			Schema:  CreateSchema(),
+		},
+		kinds: map[StateKey]stateKind{},
+	}
+
+	root, err := loader.decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	fields, err := loader.object(root, nil, "states", "actions", "goals")
+	if err != nil {
+		return nil, err
+	}
+
+	// The states are loaded first, as the actions and goals reference them
+	for _, load := range []struct {
+		field string
+		fn    func(node *domainNode) error
+	}{
+		{"states", loader.loadState},
+		{"actions", loader.loadAction},
+		{"goals", loader.loadGoal},
+	} {
+		if fields[load.field] == nil {
+			continue
+		}
+
+		items, err := loader.array(fields[load.field])
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			if err := load.fn(item); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return loader.domain, nil
+}
+
+func (loader *domainLoader) errorf(line int, format string, args ...any) error {
+	return &DomainError{File: loader.file, Line: line, Message: fmt.Sprintf(format, args...)}
+}
+
+func (loader *domainLoader) loadState(node *domainNode) error {
+	fields, err := loader.object(node, []string{"name", "type"}, "key", "value")
+	if err != nil {
+		return err
+	}
+
+	name, err := loader.name(fields["name"])
+	if err != nil {
+		return err
+	}
+	if _, ok := loader.domain.Schema.GetKey(name); ok {
+		return loader.errorf(fields["name"].line, "state %q is already declared", name)
+	}
+
+	typeName, err := loader.string(fields["type"])
+	if err != nil {
+		return err
+	}
+	kind, ok := domainStateKinds[typeName]
+	if !ok {
+		return loader.errorf(fields["type"].line, "unknown type %q", typeName)
+	}
+
+	key := loader.nextKey
+	if fields["key"] != nil {
+		value, err := loader.value(fields["key"], kindUint64)
+		if err != nil {
+			return err
+		}
+		if value.(uint64) > uint64(^StateKey(0)) {
+			return loader.errorf(fields["key"].line, "key %d out of range", value)
+		}
+		key = StateKey(value.(uint64))
+	}
+	if _, ok := loader.kinds[key]; ok {
+		return loader.errorf(node.line, "key %d of state %q is already used", key, name)
+	}
+
+	loader.kinds[key] = kind
+	loader.nextKey = key + 1
+	if err := registerStateKind(loader.domain.Schema, key, name, kind); err != nil {
+		return loader.errorf(node.line, "%v", err)
+	}
+
+	if fields["value"] != nil {
+		value, err := loader.value(fields["value"], kind)
+		if err != nil {
+			return err
+		}
+		loader.domain.states = append(loader.domain.states, createState(key, value))
+	}
+
+	return nil
+}
+
+func (loader *domainLoader) loadAction(node *domainNode) error {
+	fields, err := loader.object(node, []string{"name"}, "cost", "repeatable", "conditions", "effects")
+	if err != nil {
+		return err
+	}
+
+	name, err := loader.name(fields["name"])
+	if err != nil {
+		return err
+	}
+	if loader.domain.Actions.GetAction(name) != nil {
+		return loader.errorf(fields["name"].line, "action %q is already declared", name)
+	}
+
+	cost := float32(1)
+	if fields["cost"] != nil {
+		value, err := loader.value(fields["cost"], kindFloat64)
+		if err != nil {
+			return err
+		}
+		if value.(float64) < 0 {
+			return loader.errorf(fields["cost"].line, "cost %v of action %q is negative", value, name)
+		}
+		cost = float32(value.(float64))
+	}
+
+	var repeatable bool
+	if fields["repeatable"] != nil {
+		value, err := loader.value(fields["repeatable"], kindBool)
+		if err != nil {
+			return err
+		}
+		repeatable = value.(bool)
+	}
+
+	conditions, err := loader.conditions(fields["conditions"])
+	if err != nil {
+		return err
+	}
+
+	effects := Effects{}
+	if fields["effects"] != nil {
+		items, err := loader.array(fields["effects"])
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			effect, err := loader.effect(item)
+			if err != nil {
+				return err
+			}
+			effects = append(effects, effect)
+		}
+	}
+
+	loader.domain.Actions.AddAction(name, cost, repeatable, conditions, effects)
+
+	return nil
+}
+
+func (loader *domainLoader) loadGoal(node *domainNode) error {
+	fields, err := loader.object(node, []string{"name", "priority"}, "conditions")
+	if err != nil {
+		return err
+	}
+
+	name, err := loader.name(fields["name"])
+	if err != nil {
+		return err
+	}
+	if _, ok := loader.domain.Goals[GoalName(name)]; ok {
+		return loader.errorf(fields["name"].line, "goal %q is already declared", name)
+	}
+
+	priorityFn, err := loader.priority(fields["priority"])
+	if err != nil {
+		return err
+	}
+
+	conditions, err := loader.conditions(fields["conditions"])
+	if err != nil {
+		return err
+	}
+
+	loader.domain.Goals[GoalName(name)] = goalInterface{
+		Conditions: conditions,
+		PriorityFn: priorityFn,
+	}
+
+	return nil
+}
+
+func (loader *domainLoader) priority(node *domainNode) (GoalPriorityFn, error) {
+	if _, ok := node.value.(string); !ok {
+		value, err := loader.value(node, kindFloat64)
+		if err != nil {
+			return nil, loader.errorf(node.line, "priority must be a number or an expression")
+		}
+		priority := float32(value.(float64))
+
+		return func(Sensors) float32 { return priority }, nil
+	}
+
+	fn, err := compileExpression(node.value.(string))
+	if err != nil {
+		return nil, loader.errorf(node.line, "%v", err)
+	}
+
+	return func(sensors Sensors) float32 { return float32(fn(sensors)) }, nil
+}
+
+func (loader *domainLoader) conditions(node *domainNode) (Conditions, error) {
+	conditions := Conditions{}
+	if node == nil {
+		return conditions, nil
+	}
+
+	items, err := loader.array(node)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range items {
+		fields, err := loader.object(item, []string{"state", "value"}, "operator")
+		if err != nil {
+			return nil, err
+		}
+
+		key, kind, err := loader.state(fields["state"])
+		if err != nil {
+			return nil, err
+		}
+
+		op := EQUAL
+		if fields["operator"] != nil {
+			symbol, err := loader.string(fields["operator"])
+			if err != nil {
+				return nil, err
+			}
+			var ok bool
+			if op, ok = domainOperators[symbol]; !ok {
+				return nil, loader.errorf(fields["operator"].line, "unknown operator %q", symbol)
+			}
+			if (kind == kindBool || kind == kindString) && op != EQUAL && op != NOT_EQUAL {
+				return nil, loader.errorf(fields["operator"].line, "operator %q is not allowed on a %s state", symbol, kind)
+			}
+		}
+
+		value, err := loader.value(fields["value"], kind)
+		if err != nil {
+			return nil, err
+		}
+
+		conditions = append(conditions, createCondition(key, op, value))
+	}
+
+	return conditions, nil
+}
+
+func (loader *domainLoader) effect(node *domainNode) (EffectInterface, error) {
+	fields, err := loader.object(node, []string{"state", "value"}, "operator")
+	if err != nil {
+		return nil, err
+	}
+
+	key, kind, err := loader.state(fields["state"])
+	if err != nil {
+		return nil, err
+	}
+
+	op := SET
+	if fields["operator"] != nil {
+		symbol, err := loader.string(fields["operator"])
+		if err != nil {
+			return nil, err
+		}
+		var ok bool
+		if op, ok = domainArithmetics[symbol]; !ok {
+			return nil, loader.errorf(fields["operator"].line, "unknown operator %q", symbol)
+		}
+		if (kind == kindBool && op != SET) || (kind == kindString && op != SET && op != ADD) {
+			return nil, loader.errorf(fields["operator"].line, "operator %q is not allowed on a %s state", symbol, kind)
+		}
+	}
+
+	value, err := loader.value(fields["value"], kind)
+	if err != nil {
+		return nil, err
+	}
+	if op == DIVIDE && isZero(value) {
+		return nil, loader.errorf(fields["value"].line, "division by zero")
+	}
+
+	return createEffect(key, op, value), nil
+}
+
+// state returns the key and the type of the state referenced by node.
+func (loader *domainLoader) state(node *domainNode) (StateKey, stateKind, error) {
+	name, err := loader.string(node)
+	if err != nil {
+		return 0, kindNone, err
+	}
+
+	key, ok := loader.domain.Schema.GetKey(name)
+	if !ok {
+		return 0, kindNone, loader.errorf(node.line, "unknown state %q", name)
+	}
+
+	return key, loader.kinds[key], nil
+}
+
+func (loader *domainLoader) name(node *domainNode) (string, error) {
+	name, err := loader.string(node)
+	if err != nil {
+		return "", err
+	}
+	if name == "" {
+		return "", loader.errorf(node.line, "name must not be empty")
+	}
+
+	return name, nil
+}
+
+func (loader *domainLoader) string(node *domainNode) (string, error) {
+	value, ok := node.value.(string)
+	if !ok {
+		return "", loader.errorf(node.line, "expected a string")
+	}
+
+	return value, nil
+}
+
+// value returns the value of node, converted to the Go type of kind.
+func (loader *domainLoader) value(node *domainNode, kind stateKind) (any, error) {
+	switch kind {
+	case kindBool:
+		if value, ok := node.value.(bool); ok {
+			return value, nil
+		}
+		return nil, loader.errorf(node.line, "expected a bool")
+	case kindString:
+		return loader.string(node)
+	}
+
+	number, ok := node.value.(json.Number)
+	if !ok {
+		return nil, loader.errorf(node.line, "expected a %s", kind)
+	}
+
+	var value any
+	var err error
+	switch kind {
+	case kindInt8:
+		var v int64
+		v, err = strconv.ParseInt(number.String(), 10, 8)
+		value = int8(v)
+	case kindInt:
+		var v int64
+		v, err = strconv.ParseInt(number.String(), 10, strconv.IntSize)
+		value = int(v)
+	case kindUint8:
+		var v uint64
+		v, err = strconv.ParseUint(number.String(), 10, 8)
+		value = uint8(v)
+	case kindUint64:
+		value, err = strconv.ParseUint(number.String(), 10, 64)
+	case kindFloat64:
+		value, err = strconv.ParseFloat(number.String(), 64)
+	}
+	if err != nil {
+		return nil, loader.errorf(node.line, "%s is not a valid %s", number, kind)
+	}
+
+	return value, nil
+}
+
+// object returns the fields of node, checking that the required fields are set,
+// and that there is no other field than the required and optional ones.
+func (loader *domainLoader) object(node *domainNode, required []string, optional ...string) (map[string]*domainNode, error) {
+	fields, ok := node.value.(map[string]*domainNode)
+	if !ok {
+		return nil, loader.errorf(node.line, "expected an object")
+	}
+
+	for _, name := range required {
+		if fields[name] == nil {
+			return nil, loader.errorf(node.line, "missing field %q", name)
+		}
+	}
+	for _, name := range node.fields {
+		if !slices.Contains(required, name) && !slices.Contains(optional, name) {
+			return nil, loader.errorf(fields[name].line, "unknown field %q", name)
+		}
+	}
+
+	return fields, nil
+}
+
+func (loader *domainLoader) array(node *domainNode) ([]*domainNode, error) {
+	items, ok := node.value.([]*domainNode)
+	if !ok {
+		return nil, loader.errorf(node.line, "expected an array")
+	}
+
+	return items, nil
+}
+
+// domainNode is a JSON value of a domain file, along with its line.
+// The value is a map[string]*domainNode, a []*domainNode, a string, a json.Number, a bool or nil.
+type domainNode struct {
+	line   int
+	value  any
+	fields []string // Names of the fields of an object, in the file order
+}
+
+// decode returns the root node of data, keeping the line of each value, unlike json.Unmarshal.
+func (loader *domainLoader) decode(data []byte) (*domainNode, error) {
+	var newlines []int
+	for i, c := range data {
+		if c == '\n' {
+			newlines = append(newlines, i)
+		}
+	}
+	line := func(offset int64) int {
+		n, _ := slices.BinarySearch(newlines, int(offset))
+		return n + 1
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	root, err := decodeDomainNode(decoder, line)
+	if err == nil {
+		if _, err = decoder.Token(); err == io.EOF {
+			return root, nil
+		} else if err == nil {
+			return nil, loader.errorf(line(decoder.InputOffset()), "unexpected data after the domain")
+		}
+	}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return nil, loader.errorf(line(syntaxErr.Offset), "%v", syntaxErr)
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, loader.errorf(line(int64(len(data))), "unexpected end of file")
+	}
+	var domainErr *DomainError
+	if errors.As(err, &domainErr) {
+		domainErr.File = loader.file
+		return nil, domainErr
+	}
+
+	return nil, loader.errorf(line(decoder.InputOffset()), "%v", err)
+}
+
+func decodeDomainNode(decoder *json.Decoder, line func(offset int64) int) (*domainNode, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	node := &domainNode{line: line(decoder.InputOffset()), value: token}
+
+	switch token {
+	case json.Delim('{'):
+		fields := map[string]*domainNode{}
+		for decoder.More() {
+			name, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := fields[name.(string)]; ok {
+				return nil, &DomainError{Line: line(decoder.InputOffset()), Message: fmt.Sprintf("duplicated field %q", name)}
+			}
+
+			field, err := decodeDomainNode(decoder, line)
+			if err != nil {
+				return nil, err
+			}
+			fields[name.(string)] = field
+			node.fields = append(node.fields, name.(string))
+		}
+		node.value = fields
+	case json.Delim('['):
+		items := []*domainNode{}
+		for decoder.More() {
+			item, err := decodeDomainNode(decoder, line)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		node.value = items
+	default:
+		return node, nil
+	}
+
+	// Closing delimiter
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+
+	return node, nil
+}
+
+// registerStateKind declares the key with its name, and the Go type of kind in the schema.
+func registerStateKind(schema *Schema, key StateKey, name string, kind stateKind) error {
+	switch kind {
+	case kindInt8:
+		return RegisterNamedState[int8](schema, key, name)
+	case kindInt:
+		return RegisterNamedState[int](schema, key, name)
+	case kindUint8:
+		return RegisterNamedState[uint8](schema, key, name)
+	case kindUint64:
+		return RegisterNamedState[uint64](schema, key, name)
+	case kindFloat64:
+		return RegisterNamedState[float64](schema, key, name)
+	case kindBool:
+		return RegisterNamedState[bool](schema, key, name)
+	default:
+		return RegisterNamedState[string](schema, key, name)
+	}
+}
+
+// createState returns the state of key, with a value returned by domainLoader.value.
+func createState(key StateKey, value any) StateInterface {
+	switch value := value.(type) {
+	case int8:
+		return State[int8]{Key: key, Value: value}
+	case int:
+		return State[int]{Key: key, Value: value}
+	case uint8:
+		return State[uint8]{Key: key, Value: value}
+	case uint64:
+		return State[uint64]{Key: key, Value: value}
+	case float64:
+		return State[float64]{Key: key, Value: value}
+	case bool:
+		return State[bool]{Key: key, Value: value}
+	default:
+		return State[string]{Key: key, Value: value.(string)}
+	}
+}
+
+// createCondition returns the condition on key, with a value returned by domainLoader.value.
+func createCondition(key StateKey, op operator, value any) ConditionInterface {
+	switch value := value.(type) {
+	case int8:
+		return &Condition[int8]{Key: key, Value: value, Operator: op}
+	case int:
+		return &Condition[int]{Key: key, Value: value, Operator: op}
+	case uint8:
+		return &Condition[uint8]{Key: key, Value: value, Operator: op}
+	case uint64:
+		return &Condition[uint64]{Key: key, Value: value, Operator: op}
+	case float64:
+		return &Condition[float64]{Key: key, Value: value, Operator: op}
+	case bool:
+		return &ConditionBool{Key: key, Value: value, Operator: op}
+	default:
+		return &ConditionString{Key: key, Value: value.(string), Operator: op}
+	}
+}
+
+// createEffect returns the effect on key, with a value returned by domainLoader.value.
+func createEffect(key StateKey, op arithmetic, value any) EffectInterface {
+	switch value := value.(type) {
+	case int8:
+		return Effect[int8]{Key: key, Value: value, Operator: op}
+	case int:
+		return Effect[int]{Key: key, Value: value, Operator: op}
+	case uint8:
+		return Effect[uint8]{Key: key, Value: value, Operator: op}
+	case uint64:
+		return Effect[uint64]{Key: key, Value: value, Operator: op}
+	case float64:
+		return Effect[float64]{Key: key, Value: value, Operator: op}
+	case bool:
+		return EffectBool{Key: key, Value: value, Operator: op}
+	default:
+		return EffectString{Key: key, Value: value.(string), Operator: op}
+	}
+}
+
+func isZero(value any) bool {
+	switch value := value.(type) {
+	case int8:
+		return value == 0
+	case int:
+		return value == 0
+	case uint8:
+		return value == 0
+	case uint64:
+		return value == 0
+	case float64:
+		return value == 0
+	}
+
+	return false
+}
@@ -0,0 +1,242 @@
+package goapai
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testDomain = `{
+    "states": [
+        {"name": "has_axe", "type": "bool", "value": false},
+        {"name": "wood", "type": "int", "value": 0},
+        {"name": "place", "type": "string", "key": 10, "value": "home"},
+        {"name": "stamina", "type": "float64"}
+    ],
+    "actions": [
+        {
+            "name": "take_axe", "cost": 1,
+            "conditions": [{"state": "place", "value": "home"}],
+            "effects": [{"state": "has_axe", "value": true}]
+        },
+        {
+            "name": "chop_wood", "cost": 2, "repeatable": true,
+            "conditions": [{"state": "has_axe", "operator": "==", "value": true}],
+            "effects": [{"state": "wood", "operator": "+=", "value": 2}]
+        }
+    ],
+    "goals": [
+        {"name": "gather_wood", "priority": "max(cold * 2, 1)", "conditions": [{"state": "wood", "operator": ">=", "value": 4}]},
+        {"name": "rest", "priority": 0.5}
+    ]
+}`
+
+func TestLoadDomain(t *testing.T) {
+	domain, err := LoadDomain(strings.NewReader(testDomain))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	keys := map[string]StateKey{"has_axe": 0, "wood": 1, "place": 10, "stamina": 11}
+	for name, expected := range keys {
+		if key, ok := domain.GetStateKey(name); !ok || key != expected {
+			t.Errorf("Expected key %d for state %q, got %d (%v)", expected, name, key, ok)
+		}
+	}
+
+	agent := domain.CreateAgent()
+	if value, ok := GetState[string](&agent, 10); !ok || value != "home" {
+		t.Errorf("Expected initial state place=home, got %q (%v)", value, ok)
+	}
+	if agent.HasState(11) {
+		t.Errorf("Expected state stamina without value to be unset")
+	}
+
+	SetSensor(&agent, "cold", 3)
+	if priority := domain.Goals["gather_wood"].PriorityFn(agent.sensors); priority != 6 {
+		t.Errorf("Expected priority 6, got %v", priority)
+	}
+
+	result, err := FindPlan(agent, PlanOptions{MaxDepth: 10})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.GoalName != "gather_wood" {
+		t.Errorf("Expected goal gather_wood, got %s", result.GoalName)
+	}
+
+	expected := []string{"take_axe", "chop_wood", "chop_wood"}
+	if names := planNames(result.Plan); strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected plan %v, got %v", expected, names)
+	}
+	if cost := result.Plan.GetTotalCost(); cost != 5 {
+		t.Errorf("Expected total cost 5, got %v", cost)
+	}
+}
+
+func TestLoadDomainErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		domain  string
+		line    int
+		message string
+	}{
+		{
+			name:    "syntax error",
+			domain:  "{\n  \"states\": [\n    {\"name\": \"wood\",, \"type\": \"int\"}\n  ]\n}",
+			line:    3,
+			message: "invalid character",
+		},
+		{
+			name:    "truncated file",
+			domain:  "{\n  \"states\": [\n",
+			line:    3,
+			message: "unexpected end",
+		},
+		{
+			name:    "unknown field",
+			domain:  "{\n  \"states\": [\n    {\"name\": \"wood\", \"type\": \"int\",\n     \"default\": 1}\n  ]\n}",
+			line:    4,
+			message: `unknown field "default"`,
+		},
+		{
+			name:    "unknown type",
+			domain:  "{\"states\": [\n  {\"name\": \"wood\", \"type\": \"int32\"}\n]}",
+			line:    2,
+			message: `unknown type "int32"`,
+		},
+		{
+			name:    "duplicated state",
+			domain:  "{\"states\": [\n  {\"name\": \"wood\", \"type\": \"int\"},\n  {\"name\": \"wood\", \"type\": \"bool\"}\n]}",
+			line:    3,
+			message: `state "wood" is already declared`,
+		},
+		{
+			name:    "duplicated key",
+			domain:  "{\"states\": [\n  {\"name\": \"wood\", \"type\": \"int\"},\n  {\"name\": \"stone\", \"type\": \"int\", \"key\": 0}\n]}",
+			line:    3,
+			message: "key 0 of state \"stone\" is already used",
+		},
+		{
+			name:    "value type mismatch",
+			domain:  "{\"states\": [\n  {\"name\": \"wood\", \"type\": \"int\",\n   \"value\": 1.5}\n]}",
+			line:    3,
+			message: "1.5 is not a valid int",
+		},
+		{
+			name:    "unknown state",
+			domain:  "{\"actions\": [\n  {\"name\": \"chop\",\n   \"effects\": [{\"state\": \"wood\", \"value\": 1}]}\n]}",
+			line:    3,
+			message: `unknown state "wood"`,
+		},
+		{
+			name:    "operator not allowed",
+			domain:  "{\"states\": [{\"name\": \"ready\", \"type\": \"bool\"}],\n\"actions\": [\n  {\"name\": \"wait\",\n   \"conditions\": [{\"state\": \"ready\", \"operator\": \">\", \"value\": true}]}\n]}",
+			line:    4,
+			message: `operator ">" is not allowed on a bool state`,
+		},
+		{
+			name:    "division by zero",
+			domain:  "{\"states\": [{\"name\": \"wood\", \"type\": \"int\"}],\n\"actions\": [\n  {\"name\": \"split\",\n   \"effects\": [{\"state\": \"wood\", \"operator\": \"/=\", \"value\": 0}]}\n]}",
+			line:    4,
+			message: "division by zero",
+		},
+		{
+			name:    "duplicated action",
+			domain:  "{\"actions\": [\n  {\"name\": \"wait\"},\n  {\"name\": \"wait\"}\n]}",
+			line:    3,
+			message: `action "wait" is already declared`,
+		},
+		{
+			name:    "missing priority",
+			domain:  "{\"goals\": [\n  {\"name\": \"rest\"}\n]}",
+			line:    2,
+			message: `missing field "priority"`,
+		},
+		{
+			name:    "invalid expression",
+			domain:  "{\"goals\": [\n  {\"name\": \"rest\",\n   \"priority\": \"tired * (2\"}\n]}",
+			line:    3,
+			message: "missing )",
+		},
+		{
+			name:    "duplicated field",
+			domain:  "{\"goals\": [\n  {\"name\": \"rest\",\n   \"name\": \"sleep\", \"priority\": 1}\n]}",
+			line:    3,
+			message: `duplicated field "name"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := LoadDomain(strings.NewReader(test.domain))
+			if !errors.Is(err, ErrInvalidDomain) {
+				t.Fatalf("Expected ErrInvalidDomain, got %v", err)
+			}
+
+			var domainErr *DomainError
+			if !errors.As(err, &domainErr) {
+				t.Fatalf("Expected a DomainError, got %T", err)
+			}
+			if domainErr.Line != test.line {
+				t.Errorf("Expected line %d, got %d (%v)", test.line, domainErr.Line, err)
+			}
+			if !strings.Contains(domainErr.Message, test.message) {
+				t.Errorf("Expected message containing %q, got %q", test.message, domainErr.Message)
+			}
+		})
+	}
+}
+
+func TestLoadDomainFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "domain.json")
+	if err := os.WriteFile(path, []byte("{\n\"goals\": 1\n}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := LoadDomainFile(path)
+	if expected := path + ":2: expected an array"; err == nil || err.Error() != expected {
+		t.Errorf("Expected error %q, got %v", expected, err)
+	}
+}
+
+func TestCompileExpression(t *testing.T) {
+	sensors := Sensors{"hunger": 40, "tired": true, "distance": float32(2.5), "name": "guard"}
+
+	tests := []struct {
+		expression string
+		expected   float64
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"hunger / 10 - 1", 3},
+		{"-distance", -2.5},
+		{"tired && hunger > 30", 1},
+		{"!tired || hunger <= 30", 0},
+		{"hunger == 40 && distance != 2.5", 0},
+		{"unknown + name", 0},
+		{"hunger / unknown", 0},
+		{"min(hunger, 10, distance * 2)", 5},
+		{"max(0, 1 - hunger)", 0},
+		{"\thunger\n/ 10\r\n", 4},
+	}
+
+	for _, test := range tests {
+		fn, err := compileExpression(test.expression)
+		if err != nil {
+			t.Errorf("Expected no error for %q, got %v", test.expression, err)
+			continue
+		}
+		if result := fn(sensors); result != test.expected {
+			t.Errorf("Expected %q to be %v, got %v", test.expression, test.expected, result)
+		}
+	}
+
+	for _, expression := range []string{"", "1 +", "min(1", "hunger 2", "3..2", "max 1"} {
+		if _, err := compileExpression(expression); err == nil {
+			t.Errorf("Expected an error for %q", expression)
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package goapai
 
-import "testing"
+import (
+	"errors"
+	"testing"
+)
 
 func TestEffectString_GetKey(t *testing.T) {
 	effect := EffectString{Key: 42, Value: "test"}
@@ -343,3 +346,105 @@ func TestEffect_GetKey(t *testing.T) {
 		t.Errorf("GetKey() = %v, want 42", got)
 	}
 }
+
+func TestEffectFn_Apply(t *testing.T) {
+	agent := CreateAgent(Goals{}, Actions{})
+	SetState[int](&agent, 1, 2)
+	SetState[bool](&agent, 2, true)
+	SetSensor(&agent, "chest", 5)
+
+	effect := EffectFn{
+		Key: 1,
+		ApplyFn: func(sensors Sensors, editor WorldEditor) error {
+			wood, _ := GetEditorState[int](editor, 1)
+			if err := SetEditorState(editor, 1, wood+sensors.GetSensor("chest").(int)); err != nil {
+				return err
+			}
+			editor.DeleteState(2)
+			return nil
+		},
+	}
+
+	if effect.GetKey() != 1 {
+		t.Errorf("GetKey() = %v, want 1", effect.GetKey())
+	}
+	if effect.check(agent.w) {
+		t.Error("Expected EffectFn to always have an impact")
+	}
+
+	w := agent.w.clone()
+	if err := effect.apply(&w); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if value, _ := lookupState[int](&w, 1); value != 7 {
+		t.Errorf("Expected value 7, got %d", value)
+	}
+	if w.hasState(2) {
+		t.Error("Expected state 2 to be deleted")
+	}
+
+	expected := agent.w.clone()
+	_ = storeState(&expected, 1, 7)
+	expected.deleteState(2)
+	if w.hash != expected.hash {
+		t.Error("Expected the world hash to be updated")
+	}
+
+	// The world of the agent is not modified
+	if value, _ := GetState[int](&agent, 1); value != 2 {
+		t.Errorf("Expected agent state to remain 2, got %d", value)
+	}
+}
+
+func TestEffectFn_Plan(t *testing.T) {
+	errEmpty := errors.New("chest is empty")
+
+	actions := Actions{}
+	actions.AddAction("open_chest", 1.0, false, Conditions{}, Effects{
+		EffectFn{
+			Key: 1,
+			ApplyFn: func(sensors Sensors, editor WorldEditor) error {
+				content := sensors.GetSensor("chest").(int)
+				if content == 0 {
+					return errEmpty
+				}
+				wood, _ := GetEditorState[int](editor, 1)
+				return SetEditorState(editor, 1, wood+content)
+			},
+		},
+	})
+	actions.AddAction("chop_wood", 3.0, true, Conditions{}, Effects{
+		Effect[int]{Key: 1, Value: 1, Operator: ADD},
+	})
+
+	goals := Goals{
+		"get_wood": {
+			Conditions: Conditions{&Condition[int]{Key: 1, Value: 4, Operator: UPPER_OR_EQUAL}},
+			PriorityFn: func(sensors Sensors) float32 {
+				return 1.0
+			},
+		},
+	}
+
+	agent := CreateAgent(goals, actions)
+	SetState[int](&agent, 1, 0)
+	SetSensor(&agent, "chest", 4)
+
+	result, err := FindPlan(agent, PlanOptions{MaxDepth: 10})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.Plan) != 2 || result.Plan[1].name != "open_chest" {
+		t.Errorf("Expected plan [open_chest], got %d actions", len(result.Plan))
+	}
+
+	// An error of ApplyFn discards the action
+	SetSensor(&agent, "chest", 0)
+	result, err = FindPlan(agent, PlanOptions{MaxDepth: 10})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.Plan) != 5 {
+		t.Errorf("Expected plan with 5 actions (root + 4 chop_wood), got %d", len(result.Plan))
+	}
+}
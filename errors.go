@@ -0,0 +1,38 @@
+package goapai
+
+import "errors"
+
+// Errors returned by the planner. They can be matched with errors.Is, as the
+// planner wraps them with contextual information (goal name, failing action).
+var (
+	// ErrNoGoalAvailable is returned when no goal has a priority above zero.
+	ErrNoGoalAvailable = errors.New("no goal available")
+	// ErrGoalUnreachable is returned when the search space was fully explored without matching the goal.
+	ErrGoalUnreachable = errors.New("goal unreachable")
+	// ErrMaxDepth is returned when the goal could not be matched within the maximum depth.
+	ErrMaxDepth = errors.New("depth limit reached")
+	// ErrTypeMismatch is returned when an effect is applied on a state of another type.
+	ErrTypeMismatch = errors.New("type does not match")
+	// ErrNodeBudget is returned when the search expanded more nodes than allowed.
+	ErrNodeBudget = errors.New("node budget exhausted")
+	// ErrOpenSetBudget is returned when the open set of the search grew larger than allowed.
+	ErrOpenSetBudget = errors.New("open set budget exhausted")
+	// ErrDeadline is returned when the search ran out of time.
+	ErrDeadline = errors.New("planning deadline exceeded")
+	// ErrCanceled is returned when the context of the search was canceled.
+	ErrCanceled = errors.New("planning canceled")
+	// ErrPoolClosed is returned when a request is submitted to a closed PlannerPool.
+	ErrPoolClosed = errors.New("planner pool closed")
+	// ErrSchemaViolation is returned when a state is registered or used in a way that does not match its Schema.
+	ErrSchemaViolation = errors.New("schema violation")
+	// ErrValidation is returned by LintReport.Err for each issue found by Lint.
+	ErrValidation = errors.New("validation failed")
+	// ErrUnknownGoal is returned when a goal name is not one of the agent's goals.
+	ErrUnknownGoal = errors.New("unknown goal")
+	// ErrUnknownAction is returned when an action name is not one of the agent's actions.
+	ErrUnknownAction = errors.New("unknown action")
+	// ErrInvalidDomain is returned when a domain file cannot be loaded, wrapped in a DomainError.
+	ErrInvalidDomain = errors.New("invalid domain")
+	// ErrInvalidPlan is returned when a plan cannot be performed from the agent's world state, wrapped in a PlanStepError.
+	ErrInvalidPlan = errors.New("invalid plan")
+)
@@ -0,0 +1,245 @@
+package goapai
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ActionExplanation tells why an action is not part of the plan of a goal, see ExplainAction.
+type ActionExplanation struct {
+	GoalName   GoalName
+	ActionName string
+	Plan       Plan  // Plan found for the goal, empty if none
+	PlanErr    error // Reason why no plan was found, nil otherwise
+	InPlan     bool
+
+	// Filtered is true if the effects of the action already hold in the agent's world state:
+	// the search ignores the action, considering it cannot lead towards the goal.
+	Filtered bool
+	// Applied is the number of nodes reached by performing the action, that did not lead to the plan.
+	Applied int
+	// Rejections are the expanded nodes where the action could not be performed.
+	Rejections []ActionRejection
+}
+
+// ActionRejection is an expanded node where an action could not be performed.
+type ActionRejection struct {
+	Path             []string    // Actions leading to the node, empty for the starting node
+	Status           traceStatus // REJECTED_CONDITIONS, REJECTED_REPETITION, REJECTED_DEPTH, ...
+	Reason           string      // Details of the rejection
+	FailedConditions []string    // Conditions that do not hold in the node, for REJECTED_CONDITIONS
+}
+
+// GoalExplanation tells which conditions of a goal hold, and which actions can supply them, see ExplainGoal.
+type GoalExplanation struct {
+	GoalName   GoalName
+	Priority   float32
+	Conditions []ConditionExplanation
+}
+
+// ConditionExplanation tells whether a goal condition holds, and which actions can supply it.
+type ConditionExplanation struct {
+	Condition string   // Readable form of the condition, e.g. "wood >= 5"
+	Satisfied bool     // The condition holds in the agent's world state
+	Suppliers []string // Actions whose effects can make the condition hold
+}
+
+// ExplainAction answers the question "why didn't the agent use this action for this goal?".
+//
+// The goal is planned with a forward search and options, recording the nodes explored as with
+// PlanOptions.Trace. The explanation reports whether the action was ignored because its effects already
+// hold in the agent's world state, and for each expanded node where it could not be performed, the
+// reason: failed conditions, non-repeatable action already performed on the path, effects already
+// satisfied, or depth limit reached. For an action template, all the actions bound from it are explained.
+// It returns ErrUnknownGoal or ErrUnknownAction if the goal or the action does not exist, and an error
+// matching errors.ErrUnsupported for a goal with a Task or a BACKWARD Direction, as only the forward
+// searches record their nodes.
+//
+// Example:
+//
+//	explanation, err := goapai.ExplainAction(guard.agent, "defend", "raise_alarm", goapai.PlanOptions{MaxDepth: 10})
+//	fmt.Println(explanation)
+func ExplainAction(agent Agent, goalName GoalName, actionName string, options PlanOptions) (ActionExplanation, error) {
+	goal, ok := agent.goals[goalName]
+	if !ok {
+		return ActionExplanation{}, fmt.Errorf("%w: %q", ErrUnknownGoal, goalName)
+	}
+	action := agent.actions.GetAction(actionName)
+	if action == nil {
+		return ActionExplanation{}, fmt.Errorf("%w: %q", ErrUnknownAction, actionName)
+	}
+	if goal.Task != nil {
+		return ActionExplanation{}, fmt.Errorf("%w: goal %q is planned with a Task", errors.ErrUnsupported, goalName)
+	}
+	if options.Direction == BACKWARD {
+		return ActionExplanation{}, fmt.Errorf("%w: BACKWARD search", errors.ErrUnsupported)
+	}
+
+	w := agent.planningWorld()
+	explanation := ActionExplanation{
+		GoalName:   goalName,
+		ActionName: actionName,
+		Filtered:   action.template == nil && action.effects.satisfyStates(w),
+	}
+
+	trace := &SearchTrace{}
+	trace.startGoal(goalName)
+	options.Trace = trace
+	search := createForwardSearch(w, goalInterface{Conditions: goal.Conditions}, agent.actions, options)
+	for !search.expand() {
+	}
+	explanation.Plan, _, explanation.PlanErr = search.result()
+
+	matches := func(name string) bool {
+		return name == actionName || (action.template != nil && strings.HasPrefix(name, actionName+"("))
+	}
+	explanation.InPlan = slices.ContainsFunc(explanation.Plan, func(planned *Action) bool { return matches(planned.name) })
+
+	inPlan := trace.planNodes()
+	for _, n := range trace.Nodes {
+		if n.Parent < 0 || !matches(n.Action) || inPlan[n.ID] {
+			continue
+		}
+
+		if n.Status < REJECTED_DEPTH {
+			explanation.Applied++
+			continue
+		}
+
+		explanation.Rejections = append(explanation.Rejections, ActionRejection{
+			Path:             trace.path(n.Parent),
+			Status:           n.Status,
+			Reason:           n.Reason,
+			FailedConditions: n.FailedConditions,
+		})
+	}
+
+	return explanation, nil
+}
+
+// ExplainGoal reports which conditions of the goal hold in the agent's world state, and which actions
+// can supply each of them. The actions of the HTN task of the goal are considered as well, and the
+// action templates are bound against the agent's world state. A condition that does not hold and has
+// no supplier makes the goal unreachable. It returns ErrUnknownGoal if the goal does not exist.
+func ExplainGoal(agent Agent, goalName GoalName) (GoalExplanation, error) {
+	goal, ok := agent.goals[goalName]
+	if !ok {
+		return GoalExplanation{}, fmt.Errorf("%w: %q", ErrUnknownGoal, goalName)
+	}
+
+	w := agent.planningWorld()
+	actions := slices.Clone(agent.actions)
+	if goal.Task != nil {
+		actions = append(actions, taskActions(goal.Task, map[*Task]bool{})...)
+	}
+	actions = bindActions(w, actions, nil)
+
+	explanation := GoalExplanation{GoalName: goalName}
+	if goal.PriorityFn != nil {
+		explanation.Priority = goal.PriorityFn(agent.sensors)
+	}
+
+	for _, condition := range goal.Conditions {
+		conditionExplanation := ConditionExplanation{
+			Condition: describeCondition(condition, w.schema()),
+			Satisfied: condition.Check(w),
+		}
+		for _, action := range actions {
+			if slices.ContainsFunc(action.effects, func(effect EffectInterface) bool { return canProduce(effect, condition) }) {
+				conditionExplanation.Suppliers = append(conditionExplanation.Suppliers, action.name)
+			}
+		}
+		explanation.Conditions = append(explanation.Conditions, conditionExplanation)
+	}
+
+	return explanation, nil
+}
+
+// Unsupplied returns the conditions that do not hold in the agent's world state, and that no action can supply.
+func (explanation GoalExplanation) Unsupplied() []string {
+	var unsupplied []string
+	for _, condition := range explanation.Conditions {
+		if !condition.Satisfied && len(condition.Suppliers) == 0 {
+			unsupplied = append(unsupplied, condition.Condition)
+		}
+	}
+
+	return unsupplied
+}
+
+func (explanation GoalExplanation) String() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "goal %q, priority %g:\n", explanation.GoalName, explanation.Priority)
+	for _, condition := range explanation.Conditions {
+		switch {
+		case condition.Satisfied:
+			fmt.Fprintf(&b, "  %s: satisfied\n", condition.Condition)
+		case len(condition.Suppliers) == 0:
+			fmt.Fprintf(&b, "  %s: no action can supply it\n", condition.Condition)
+		default:
+			fmt.Fprintf(&b, "  %s: supplied by %s\n", condition.Condition, strings.Join(condition.Suppliers, ", "))
+		}
+	}
+
+	return b.String()
+}
+
+func (explanation ActionExplanation) String() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "action %q for goal %q:\n", explanation.ActionName, explanation.GoalName)
+	switch {
+	case explanation.PlanErr != nil:
+		fmt.Fprintf(&b, "  no plan: %v\n", explanation.PlanErr)
+	case explanation.InPlan:
+		b.WriteString("  in the plan\n")
+	default:
+		fmt.Fprintf(&b, "  not in the plan [%s]\n", strings.Join(planActionNames(explanation.Plan), ", "))
+	}
+
+	if explanation.Filtered {
+		b.WriteString("  ignored: its effects already hold in the world state\n")
+	}
+	if explanation.Applied > 0 {
+		fmt.Fprintf(&b, "  performed in %d nodes not leading to the plan\n", explanation.Applied)
+	}
+
+	for _, rejection := range explanation.Rejections {
+		path := "start"
+		if len(rejection.Path) > 0 {
+			path = strings.Join(rejection.Path, " > ")
+		}
+
+		reason := rejection.Status.String()
+		if rejection.Reason != "" {
+			reason += ": " + rejection.Reason
+		}
+		fmt.Fprintf(&b, "  after %s: %s\n", path, reason)
+	}
+
+	return b.String()
+}
+
+// path returns the actions leading to the node id of the trace.
+func (trace *SearchTrace) path(id int) []string {
+	var path []string
+	for ; trace.Nodes[id].Parent >= 0; id = trace.Nodes[id].Parent {
+		path = append(path, trace.Nodes[id].Action)
+	}
+	slices.Reverse(path)
+
+	return path
+}
+
+// planActionNames returns the names of the actions of the plan, without the starting node.
+func planActionNames(plan Plan) []string {
+	names := make([]string, 0, len(plan))
+	for _, action := range plan.steps() {
+		names = append(names, action.name)
+	}
+
+	return names
+}
@@ -0,0 +1,164 @@
+package goapai
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// createGuardAgent creates a guard that can alert the other guards by shouting,
+// or through the alarm that is not ready.
+func createGuardAgent() Agent {
+	actions := Actions{}
+	actions.AddAction("raise_alarm", 1, false, Conditions{
+		&ConditionBool{Key: 3, Value: true, Operator: EQUAL},
+	}, Effects{
+		EffectBool{Key: 1, Value: true, Operator: SET},
+	})
+	actions.AddAction("shout", 5, false, Conditions{}, Effects{
+		EffectBool{Key: 1, Value: true, Operator: SET},
+	})
+	actions.AddAction("patrol", 1, false, Conditions{}, Effects{
+		EffectBool{Key: 2, Value: true, Operator: SET},
+	})
+
+	goals := Goals{
+		"defend": {
+			Conditions: Conditions{&ConditionBool{Key: 1, Value: true, Operator: EQUAL}},
+			PriorityFn: func(sensors Sensors) float32 { return 1 },
+		},
+		"hold": {
+			Conditions: Conditions{
+				&ConditionBool{Key: 1, Value: true, Operator: EQUAL},
+				&ConditionBool{Key: 2, Value: true, Operator: EQUAL},
+				&ConditionBool{Key: 4, Value: true, Operator: EQUAL},
+			},
+			PriorityFn: func(sensors Sensors) float32 { return 0.5 },
+		},
+	}
+
+	agent := CreateAgent(goals, actions)
+	agent.SetStates(
+		State[bool]{Key: 1, Value: false},
+		State[bool]{Key: 2, Value: true},
+		State[bool]{Key: 3, Value: false},
+	)
+
+	return agent
+}
+
+func TestExplainAction(t *testing.T) {
+	agent := createGuardAgent()
+	options := PlanOptions{MaxDepth: 5}
+
+	explanation, err := ExplainAction(agent, "defend", "raise_alarm", options)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if explanation.InPlan || explanation.Filtered || explanation.PlanErr != nil {
+		t.Errorf("Expected raise_alarm to be considered but not planned, got %+v", explanation)
+	}
+	if len(explanation.Rejections) != 1 {
+		t.Fatalf("Expected 1 rejection, got %d", len(explanation.Rejections))
+	}
+
+	rejection := explanation.Rejections[0]
+	if rejection.Status != REJECTED_CONDITIONS || len(rejection.Path) != 0 || !slices.Equal(rejection.FailedConditions, []string{"3 == true"}) {
+		t.Errorf("Expected the condition 3 == true to fail at the start, got %+v", rejection)
+	}
+	if description := explanation.String(); !strings.Contains(description, "not in the plan [shout]") ||
+		!strings.Contains(description, "after start: conditions failed: 3 == true") {
+		t.Errorf("Expected the description of the rejection, got %s", description)
+	}
+
+	explanation, err = ExplainAction(agent, "defend", "patrol", options)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !explanation.Filtered || len(explanation.Rejections) != 0 || explanation.Applied != 0 {
+		t.Errorf("Expected patrol to be filtered out, got %+v", explanation)
+	}
+
+	explanation, err = ExplainAction(agent, "defend", "shout", options)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !explanation.InPlan {
+		t.Errorf("Expected shout to be in the plan, got %+v", explanation)
+	}
+
+	if _, err := ExplainAction(agent, "flee", "shout", options); !errors.Is(err, ErrUnknownGoal) {
+		t.Errorf("Expected ErrUnknownGoal, got %v", err)
+	}
+	if _, err := ExplainAction(agent, "defend", "dance", options); !errors.Is(err, ErrUnknownAction) {
+		t.Errorf("Expected ErrUnknownAction, got %v", err)
+	}
+	if _, err := ExplainAction(agent, "defend", "shout", PlanOptions{MaxDepth: 5, Direction: BACKWARD}); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("Expected errors.ErrUnsupported, got %v", err)
+	}
+
+	task, actions := createHTNDomain()
+	if _, err := ExplainAction(*createHTNAgent(task, actions), "stay_warm", "sit", options); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("Expected errors.ErrUnsupported, got %v", err)
+	}
+}
+
+func TestExplainAction_Repetition(t *testing.T) {
+	agent := createTraceAgent(t)
+
+	explanation, err := ExplainAction(agent, "gather_wood", "take_axe", PlanOptions{MaxDepth: 5})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !explanation.InPlan {
+		t.Errorf("Expected take_axe to be in the plan")
+	}
+
+	for _, rejection := range explanation.Rejections {
+		if rejection.Status != REJECTED_REPETITION || rejection.Path[0] != "take_axe" {
+			t.Errorf("Expected take_axe to be rejected after take_axe only, got %+v", rejection)
+		}
+	}
+	if len(explanation.Rejections) == 0 {
+		t.Error("Expected take_axe to be rejected as not repeatable")
+	}
+}
+
+func TestExplainGoal(t *testing.T) {
+	agent := createGuardAgent()
+
+	explanation, err := ExplainGoal(agent, "hold")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if explanation.Priority != 0.5 {
+		t.Errorf("Expected priority 0.5, got %v", explanation.Priority)
+	}
+
+	expected := []ConditionExplanation{
+		{Condition: "1 == true", Satisfied: false, Suppliers: []string{"raise_alarm", "shout"}},
+		{Condition: "2 == true", Satisfied: true, Suppliers: []string{"patrol"}},
+		{Condition: "4 == true", Satisfied: false},
+	}
+	if len(explanation.Conditions) != len(expected) {
+		t.Fatalf("Expected %d conditions, got %d", len(expected), len(explanation.Conditions))
+	}
+	for i, condition := range explanation.Conditions {
+		if condition.Condition != expected[i].Condition || condition.Satisfied != expected[i].Satisfied ||
+			!slices.Equal(condition.Suppliers, expected[i].Suppliers) {
+			t.Errorf("Expected %+v, got %+v", expected[i], condition)
+		}
+	}
+
+	if unsupplied := explanation.Unsupplied(); !slices.Equal(unsupplied, []string{"4 == true"}) {
+		t.Errorf("Expected the condition 4 == true to be unsupplied, got %v", unsupplied)
+	}
+	if description := explanation.String(); !strings.Contains(description, "4 == true: no action can supply it") {
+		t.Errorf("Expected the description of the unsupplied condition, got %s", description)
+	}
+
+	if _, err := ExplainGoal(agent, "flee"); !errors.Is(err, ErrUnknownGoal) {
+		t.Errorf("Expected ErrUnknownGoal, got %v", err)
+	}
+}
@@ -0,0 +1,361 @@
+package goapai
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// expressionFn evaluates a compiled expression against the sensors.
+type expressionFn func(sensors Sensors) float64
+
+// expressionParser compiles the priority expressions of the domain files.
+//
+// An expression combines numbers, true and false, sensor names, the arithmetic operators (+, -, *, /),
+// the relational operators (==, !=, <=, <, >=, >), the logical operators (!, &&, ||), parentheses,
+// and the min and max functions. The sensors are read as numbers: true is 1, and false, a missing
+// sensor, or a sensor of another type are 0. The relational and logical operators return 1 or 0,
+// and a division by zero returns 0.
+type expressionParser struct {
+	source string
+	pos    int
+}
+
+// compileExpression returns the function evaluating the expression.
+func compileExpression(source string) (expressionFn, error) {
+	parser := &expressionParser{source: source}
+
+	fn, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	parser.skipSpaces()
+	if parser.pos < len(parser.source) {
+		return nil, parser.errorf("unexpected %q", parser.source[parser.pos:])
+	}
+
+	return fn, nil
+}
+
+func (parser *expressionParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("expression %q, column %d: %s", parser.source, parser.pos+1, fmt.Sprintf(format, args...))
+}
+
+func (parser *expressionParser) skipSpaces() {
+	for parser.pos < len(parser.source) {
+		c, size := utf8.DecodeRuneInString(parser.source[parser.pos:])
+		if !unicode.IsSpace(c) {
+			return
+		}
+		parser.pos += size
+	}
+}
+
+// consume skips the token if it is next in the source, and returns true in this case.
+func (parser *expressionParser) consume(token string) bool {
+	parser.skipSpaces()
+	if !strings.HasPrefix(parser.source[parser.pos:], token) {
+		return false
+	}
+	parser.pos += len(token)
+
+	return true
+}
+
+func (parser *expressionParser) parseOr() (expressionFn, error) {
+	left, err := parser.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for parser.consume("||") {
+		right, err := parser.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = func(l, r expressionFn) expressionFn {
+			return func(sensors Sensors) float64 {
+				return boolToFloat(l(sensors) != 0 || r(sensors) != 0)
+			}
+		}(left, right)
+	}
+
+	return left, nil
+}
+
+func (parser *expressionParser) parseAnd() (expressionFn, error) {
+	left, err := parser.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+
+	for parser.consume("&&") {
+		right, err := parser.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+		left = func(l, r expressionFn) expressionFn {
+			return func(sensors Sensors) float64 {
+				return boolToFloat(l(sensors) != 0 && r(sensors) != 0)
+			}
+		}(left, right)
+	}
+
+	return left, nil
+}
+
+func (parser *expressionParser) parseComparison() (expressionFn, error) {
+	left, err := parser.parseSum()
+	if err != nil {
+		return nil, err
+	}
+
+	// The operators of two characters are tried first, so that "<=" is not read as "<"
+	for _, token := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if !parser.consume(token) {
+			continue
+		}
+
+		right, err := parser.parseSum()
+		if err != nil {
+			return nil, err
+		}
+
+		compare := map[string]func(l, r float64) bool{
+			"==": func(l, r float64) bool { return l == r },
+			"!=": func(l, r float64) bool { return l != r },
+			"<=": func(l, r float64) bool { return l <= r },
+			">=": func(l, r float64) bool { return l >= r },
+			"<":  func(l, r float64) bool { return l < r },
+			">":  func(l, r float64) bool { return l > r },
+		}[token]
+
+		return func(sensors Sensors) float64 {
+			return boolToFloat(compare(left(sensors), right(sensors)))
+		}, nil
+	}
+
+	return left, nil
+}
+
+func (parser *expressionParser) parseSum() (expressionFn, error) {
+	left, err := parser.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		var subtract bool
+		switch {
+		case parser.consume("+"):
+		case parser.consume("-"):
+			subtract = true
+		default:
+			return left, nil
+		}
+
+		right, err := parser.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		left = func(l, r expressionFn, subtract bool) expressionFn {
+			if subtract {
+				return func(sensors Sensors) float64 { return l(sensors) - r(sensors) }
+			}
+			return func(sensors Sensors) float64 { return l(sensors) + r(sensors) }
+		}(left, right, subtract)
+	}
+}
+
+func (parser *expressionParser) parseProduct() (expressionFn, error) {
+	left, err := parser.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		var divide bool
+		switch {
+		case parser.consume("*"):
+		case parser.consume("/"):
+			divide = true
+		default:
+			return left, nil
+		}
+
+		right, err := parser.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = func(l, r expressionFn, divide bool) expressionFn {
+			if divide {
+				return func(sensors Sensors) float64 {
+					divisor := r(sensors)
+					if divisor == 0 {
+						return 0
+					}
+					return l(sensors) / divisor
+				}
+			}
+			return func(sensors Sensors) float64 { return l(sensors) * r(sensors) }
+		}(left, right, divide)
+	}
+}
+
+func (parser *expressionParser) parseUnary() (expressionFn, error) {
+	switch {
+	case parser.consume("-"):
+		operand, err := parser.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(sensors Sensors) float64 { return -operand(sensors) }, nil
+	case parser.consume("!"):
+		operand, err := parser.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(sensors Sensors) float64 { return boolToFloat(operand(sensors) == 0) }, nil
+	}
+
+	return parser.parsePrimary()
+}
+
+func (parser *expressionParser) parsePrimary() (expressionFn, error) {
+	if parser.consume("(") {
+		fn, err := parser.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !parser.consume(")") {
+			return nil, parser.errorf("missing )")
+		}
+		return fn, nil
+	}
+
+	parser.skipSpaces()
+	start := parser.pos
+	if start == len(parser.source) {
+		return nil, parser.errorf("unexpected end of expression")
+	}
+
+	if c := rune(parser.source[start]); unicode.IsDigit(c) || c == '.' {
+		for parser.pos < len(parser.source) && (unicode.IsDigit(rune(parser.source[parser.pos])) || parser.source[parser.pos] == '.') {
+			parser.pos++
+		}
+		number := parser.source[start:parser.pos]
+		value, err := strconv.ParseFloat(number, 64)
+		if err != nil {
+			parser.pos = start
+			return nil, parser.errorf("invalid number %q", number)
+		}
+		return func(Sensors) float64 { return value }, nil
+	}
+
+	for parser.pos < len(parser.source) && isIdentifierChar(rune(parser.source[parser.pos]), parser.pos == start) {
+		parser.pos++
+	}
+	name := parser.source[start:parser.pos]
+	if name == "" {
+		return nil, parser.errorf("unexpected %q", parser.source[start:])
+	}
+
+	switch name {
+	case "true":
+		return func(Sensors) float64 { return 1 }, nil
+	case "false":
+		return func(Sensors) float64 { return 0 }, nil
+	case "min", "max":
+		return parser.parseCall(name)
+	}
+
+	return func(sensors Sensors) float64 { return sensorNumber(sensors, name) }, nil
+}
+
+// parseCall parses the arguments of the min and max functions.
+func (parser *expressionParser) parseCall(name string) (expressionFn, error) {
+	if !parser.consume("(") {
+		return nil, parser.errorf("missing ( after %s", name)
+	}
+
+	var args []expressionFn
+	for {
+		arg, err := parser.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+
+		if parser.consume(")") {
+			break
+		}
+		if !parser.consume(",") {
+			return nil, parser.errorf("missing , or ) in %s", name)
+		}
+	}
+
+	return func(sensors Sensors) float64 {
+		result := args[0](sensors)
+		for _, arg := range args[1:] {
+			if name == "min" {
+				result = min(result, arg(sensors))
+			} else {
+				result = max(result, arg(sensors))
+			}
+		}
+		return result
+	}, nil
+}
+
+func isIdentifierChar(c rune, first bool) bool {
+	if c == '_' || unicode.IsLetter(c) {
+		return true
+	}
+
+	return !first && (unicode.IsDigit(c) || c == '.')
+}
+
+// sensorNumber returns the value of a numeric or boolean sensor, and 0 for the other sensors.
+func sensorNumber(sensors Sensors, name string) float64 {
+	switch value := sensors[name].(type) {
+	case int:
+		return float64(value)
+	case int8:
+		return float64(value)
+	case int16:
+		return float64(value)
+	case int32:
+		return float64(value)
+	case int64:
+		return float64(value)
+	case uint:
+		return float64(value)
+	case uint8:
+		return float64(value)
+	case uint16:
+		return float64(value)
+	case uint32:
+		return float64(value)
+	case uint64:
+		return float64(value)
+	case float32:
+		return float64(value)
+	case float64:
+		return value
+	case bool:
+		return boolToFloat(value)
+	}
+
+	return 0
+}
+
+func boolToFloat(value bool) float64 {
+	if value {
+		return 1
+	}
+
+	return 0
+}
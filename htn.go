@@ -0,0 +1,294 @@
+package goapai
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Task is a task of an HTN (Hierarchical Task Network) domain.
+//
+// A primitive task performs an Action. A compound task is decomposed by the first of its methods
+// whose conditions hold, and a goal task is decomposed by a GOAP plan achieving its conditions.
+// The compound tasks decomposed in a row, without performing an action, are limited to MaxDepth:
+// a task recursing without reaching a primitive task makes the search fail with ErrMaxDepth.
+// A Goal with a Task is planned by decomposing the Task instead of searching a GOAP plan, so that
+// authored HTN behaviours and emergent GOAP plans can be mixed by the goals of the same agent,
+// sharing the same world state, Conditions and Effects.
+type Task struct {
+	name    string
+	kind    taskKind
+	action  *Action    // Primitive task
+	methods []Method   // Compound task
+	goal    Conditions // Goal task, planned with actions
+	actions Actions
+}
+
+type taskKind uint8
+
+const (
+	primitiveTask taskKind = iota
+	compoundTask
+	goalTask
+)
+
+// Method is a decomposition of a compound task into an ordered list of subtasks,
+// applicable when its conditions hold.
+type Method struct {
+	Name       string
+	Conditions Conditions
+	Subtasks   []*Task
+}
+
+// CreatePrimitiveTask creates a task performing the action.
+// The action's conditions must hold, and its effects are applied to the world state.
+func CreatePrimitiveTask(action *Action) *Task {
+	return &Task{
+		name:   action.name,
+		kind:   primitiveTask,
+		action: action,
+	}
+}
+
+// CreateCompoundTask creates a task decomposed by its methods.
+// The methods are tried in order: if the decomposition of a method fails, the next one is tried.
+//
+// Example:
+//
+//	stayWarm := goapai.CreateCompoundTask("stay_warm",
+//	    goapai.Method{Name: "use_fire", Conditions: goapai.Conditions{hasFire}, Subtasks: []*goapai.Task{sitTask}},
+//	    goapai.Method{Name: "make_fire", Subtasks: []*goapai.Task{getWoodTask, lightTask, sitTask}},
+//	)
+func CreateCompoundTask(name string, methods ...Method) *Task {
+	return &Task{
+		name:    name,
+		kind:    compoundTask,
+		methods: methods,
+	}
+}
+
+// CreateGoalTask creates a task decomposed by the GOAP plan achieving conditions with actions.
+// The plan is searched in the Direction of the PlanOptions.
+func CreateGoalTask(name string, conditions Conditions, actions Actions) *Task {
+	return &Task{
+		name:    name,
+		kind:    goalTask,
+		goal:    conditions,
+		actions: actions,
+	}
+}
+
+// GetName returns the task's name.
+func (task *Task) GetName() string {
+	return task.name
+}
+
+// htnStep is an action of a partial HTN plan, linked to the previous step.
+type htnStep struct {
+	action   *Action
+	previous *htnStep
+	depth    int
+}
+
+// htnState is a partial decomposition: the world state reached, and the tasks remaining to decompose.
+type htnState struct {
+	world world
+	tasks []*Task
+	step  *htnStep
+	// nesting is the number of compound tasks decomposed since the last step, bounded by
+	// MaxDepth so that a task recursing without reaching a primitive task ends the search.
+	nesting int
+}
+
+// htnSearch decomposes the task of a goal, depth-first with backtracking over the methods.
+type htnSearch struct {
+	goal    goalInterface
+	options PlanOptions
+	stack   []htnState
+
+	stats        SearchStats
+	applyErr     error
+	depthReached bool
+
+	done bool
+	plan Plan
+	err  error
+}
+
+func createHTNSearch(from world, goal goalInterface, options PlanOptions) *htnSearch {
+	search := &htnSearch{
+		goal:    goal,
+		options: options,
+		stack:   []htnState{{world: from, tasks: []*Task{goal.Task}}},
+	}
+	search.stats.MaxOpenSet = 1
+
+	return search
+}
+
+func (search *htnSearch) expand() bool {
+	if search.done {
+		return true
+	}
+
+	if len(search.stack) == 0 {
+		switch {
+		case search.applyErr != nil:
+			return search.finish(Plan{}, search.applyErr)
+		case search.depthReached:
+			return search.finish(Plan{}, ErrMaxDepth)
+		default:
+			return search.finish(Plan{}, ErrGoalUnreachable)
+		}
+	}
+
+	if err := search.options.checkBudget(search.stats); err != nil {
+		return search.finish(Plan{}, err)
+	}
+
+	state := search.stack[len(search.stack)-1]
+	search.stack = search.stack[:len(search.stack)-1]
+	search.stats.NodesExpanded++
+
+	if len(state.tasks) == 0 {
+		if countMissingGoal(search.goal, state.world) == 0 {
+			return search.finish(buildPlanFromHTNStep(state.step), nil)
+		}
+		return false
+	}
+
+	task, tasks := state.tasks[0], state.tasks[1:]
+	switch task.kind {
+	case primitiveTask:
+		search.decomposePrimitive(state, task, tasks)
+	case goalTask:
+		search.decomposeGoal(state, task, tasks)
+	case compoundTask:
+		if state.nesting >= search.options.MaxDepth {
+			search.depthReached = true
+			return false
+		}
+
+		// Methods are pushed in reverse order, so that the first one is tried first
+		for _, method := range slices.Backward(task.methods) {
+			if method.Conditions.Check(state.world) {
+				search.push(htnState{world: state.world, tasks: slices.Concat(method.Subtasks, tasks), step: state.step, nesting: state.nesting + 1})
+			}
+		}
+	}
+
+	return false
+}
+
+func (search *htnSearch) decomposePrimitive(state htnState, task *Task, tasks []*Task) {
+	action := task.action
+	if !action.conditions.Check(state.world) {
+		return
+	}
+
+	step := search.addStep(state.step, action.withCost(action.getCost(state.world)))
+	if step == nil {
+		return
+	}
+
+	w := state.world.clone()
+	if err := action.effects.apply(&w); err != nil {
+		if search.applyErr == nil {
+			search.applyErr = fmt.Errorf("action %q: %w", action.name, err)
+		}
+		return
+	}
+
+	search.push(htnState{world: w, tasks: tasks, step: step})
+}
+
+func (search *htnSearch) decomposeGoal(state htnState, task *Task, tasks []*Task) {
+	depth := 0
+	if state.step != nil {
+		depth = state.step.depth
+	}
+
+	options := search.options
+	options.MaxDepth -= depth
+	if options.MaxNodes > 0 {
+		options.MaxNodes = max(options.MaxNodes-search.stats.NodesExpanded, 1)
+	}
+
+	searchFn := astarSearch
+	if options.Direction == BACKWARD {
+		searchFn = regressiveSearch
+	}
+	plan, stats, err := searchFn(state.world, goalInterface{Conditions: task.goal}, task.actions, options)
+	search.stats.addStats(stats)
+	if err != nil {
+		search.depthReached = search.depthReached || errors.Is(err, ErrMaxDepth)
+		return
+	}
+
+	w, err := plan.replay(state.world.clone(), nil)
+	if err != nil {
+		return
+	}
+
+	// The actions of the plan hold the cost of their step
+	step := state.step
+	for _, action := range plan.steps() {
+		if step = search.addStep(step, action); step == nil {
+			return
+		}
+	}
+
+	search.push(htnState{world: w, tasks: tasks, step: step})
+}
+
+// addStep returns the step performing action after previous, or nil if the depth limit is reached.
+func (search *htnSearch) addStep(previous *htnStep, action *Action) *htnStep {
+	step := &htnStep{action: action, previous: previous, depth: 1}
+	if previous != nil {
+		step.depth = previous.depth + 1
+	}
+
+	if step.depth > search.options.MaxDepth {
+		search.depthReached = true
+		return nil
+	}
+
+	return step
+}
+
+func (search *htnSearch) push(state htnState) {
+	search.stack = append(search.stack, state)
+	search.stats.NodesGenerated++
+	search.stats.MaxOpenSet = max(search.stats.MaxOpenSet, len(search.stack))
+}
+
+// finish ends the search with its outcome, and releases the partial decompositions.
+func (search *htnSearch) finish(plan Plan, err error) bool {
+	search.done = true
+	search.plan = plan
+	search.err = err
+	search.stack = nil
+
+	return true
+}
+
+func (search *htnSearch) result() (Plan, SearchStats, error) {
+	return search.plan, search.stats, search.err
+}
+
+func (search *htnSearch) getStats() SearchStats {
+	return search.stats
+}
+
+// buildPlanFromHTNStep returns the plan ending with step, starting with the starting node like the GOAP plans.
+func buildPlanFromHTNStep(step *htnStep) Plan {
+	plan := Plan{}
+	for ; step != nil; step = step.previous {
+		plan = append(plan, step.action)
+	}
+	plan = append(plan, &Action{start: true})
+
+	slices.Reverse(plan)
+
+	return plan
+}
@@ -0,0 +1,195 @@
+package goapai
+
+import (
+	"errors"
+	"testing"
+)
+
+// createHTNDomain creates the tasks to stay warm: sit by the fire if there is one,
+// otherwise get wood (through a GOAP goal task) and light a fire first.
+func createHTNDomain() (*Task, Actions) {
+	actions := Actions{}
+	actions.AddAction("buy_wood", 5.0, false, Conditions{
+		&ConditionBool{Key: 4, Value: true, Operator: EQUAL},
+	}, Effects{
+		EffectBool{Key: 1, Value: true, Operator: SET},
+	})
+	actions.AddAction("chop_wood", 2.0, false, Conditions{}, Effects{
+		EffectBool{Key: 1, Value: true, Operator: SET},
+	})
+	actions.AddAction("light_fire", 1.0, false, Conditions{
+		&ConditionBool{Key: 1, Value: true, Operator: EQUAL},
+	}, Effects{
+		EffectBool{Key: 2, Value: true, Operator: SET},
+	})
+	actions.AddAction("sit", 1.0, false, Conditions{
+		&ConditionBool{Key: 2, Value: true, Operator: EQUAL},
+	}, Effects{
+		EffectBool{Key: 3, Value: true, Operator: SET},
+	})
+
+	sit := CreatePrimitiveTask(actions.GetAction("sit"))
+	light := CreatePrimitiveTask(actions.GetAction("light_fire"))
+	buy := CreatePrimitiveTask(actions.GetAction("buy_wood"))
+	getWood := CreateGoalTask("get_wood", Conditions{
+		&ConditionBool{Key: 1, Value: true, Operator: EQUAL},
+	}, Actions{actions.GetAction("chop_wood")})
+
+	stayWarm := CreateCompoundTask("stay_warm",
+		Method{Name: "use_fire", Conditions: Conditions{&ConditionBool{Key: 2, Value: true, Operator: EQUAL}}, Subtasks: []*Task{sit}},
+		// Buying wood requires money: without money, the decomposition backtracks to the next method
+		Method{Name: "buy_and_light", Subtasks: []*Task{buy, light, sit}},
+		Method{Name: "chop_and_light", Subtasks: []*Task{getWood, light, sit}},
+	)
+
+	return stayWarm, actions
+}
+
+func createHTNAgent(task *Task, actions Actions) *Agent {
+	goals := Goals{
+		"stay_warm": {
+			Conditions: Conditions{&ConditionBool{Key: 3, Value: true, Operator: EQUAL}},
+			PriorityFn: func(sensors Sensors) float32 {
+				return 1.0
+			},
+			Task: task,
+		},
+	}
+
+	agent := CreateAgent(goals, actions)
+	SetState[bool](&agent, 1, false)
+	SetState[bool](&agent, 2, false)
+	SetState[bool](&agent, 3, false)
+	SetState[bool](&agent, 4, false)
+
+	return &agent
+}
+
+func planNames(plan Plan) []string {
+	names := make([]string, 0, len(plan))
+	for _, action := range plan[1:] {
+		names = append(names, action.GetName())
+	}
+
+	return names
+}
+
+func TestFindPlan_HTN(t *testing.T) {
+	task, actions := createHTNDomain()
+
+	tests := []struct {
+		name  string
+		setup func(agent *Agent)
+		want  []string
+	}{
+		{"first method", func(agent *Agent) { SetState[bool](agent, 2, true) }, []string{"sit"}},
+		{"second method", func(agent *Agent) { SetState[bool](agent, 4, true) }, []string{"buy_wood", "light_fire", "sit"}},
+		{"backtracking", func(agent *Agent) {}, []string{"chop_wood", "light_fire", "sit"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent := createHTNAgent(task, actions)
+			tt.setup(agent)
+
+			result, err := FindPlan(*agent, PlanOptions{MaxDepth: 10})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if names := planNames(result.Plan); len(names) != len(tt.want) {
+				t.Fatalf("Expected plan %v, got %v", tt.want, names)
+			} else {
+				for i := range names {
+					if names[i] != tt.want[i] {
+						t.Errorf("Expected plan %v, got %v", tt.want, names)
+						break
+					}
+				}
+			}
+			if result.NodesExpanded == 0 {
+				t.Error("Expected statistics to be filled")
+			}
+		})
+	}
+}
+
+func TestFindPlan_HTNErrors(t *testing.T) {
+	task, actions := createHTNDomain()
+
+	agent := createHTNAgent(task, actions)
+	if _, err := FindPlan(*agent, PlanOptions{MaxDepth: 2}); !errors.Is(err, ErrMaxDepth) {
+		t.Errorf("Expected ErrMaxDepth, got %v", err)
+	}
+
+	// The goal's conditions must hold after the decomposition
+	noop := CreateCompoundTask("noop", Method{Name: "nothing"})
+	agent = createHTNAgent(noop, actions)
+	if _, err := FindPlan(*agent, PlanOptions{MaxDepth: 10}); !errors.Is(err, ErrGoalUnreachable) {
+		t.Errorf("Expected ErrGoalUnreachable, got %v", err)
+	}
+
+	agent = createHTNAgent(task, actions)
+	if _, err := FindPlan(*agent, PlanOptions{MaxDepth: 10, MaxNodes: 2}); !errors.Is(err, ErrNodeBudget) {
+		t.Errorf("Expected ErrNodeBudget, got %v", err)
+	}
+
+	// A compound task recursing before any primitive task is bounded by MaxDepth
+	loop := CreateCompoundTask("loop")
+	loop.methods = []Method{{Name: "again", Subtasks: []*Task{loop, CreatePrimitiveTask(actions.GetAction("sit"))}}}
+	agent = createHTNAgent(loop, actions)
+	if _, err := FindPlan(*agent, PlanOptions{MaxDepth: 10}); !errors.Is(err, ErrMaxDepth) {
+		t.Errorf("Expected ErrMaxDepth, got %v", err)
+	}
+}
+
+func TestFindPlan_HTNBackward(t *testing.T) {
+	task, actions := createHTNDomain()
+	agent := createHTNAgent(task, actions)
+
+	// The goal task get_wood is planned with a backward search
+	result, err := FindPlan(*agent, PlanOptions{MaxDepth: 10, Direction: BACKWARD})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if names := planNames(result.Plan); len(names) != 3 || names[0] != "chop_wood" {
+		t.Errorf("Expected plan [chop_wood light_fire sit], got %v", names)
+	}
+}
+
+func TestRunner_Tick_HTNAndGOAP(t *testing.T) {
+	task, actions := createHTNDomain()
+	agent := createHTNAgent(task, actions)
+
+	// A GOAP goal becomes the prioritized one when the agent is hungry
+	actions.AddAction("eat", 1.0, false, Conditions{}, Effects{
+		EffectBool{Key: 5, Value: false, Operator: SET},
+	})
+	agent.goals["eat"] = goalInterface{
+		Conditions: Conditions{&ConditionBool{Key: 5, Value: false, Operator: EQUAL}},
+		PriorityFn: func(sensors Sensors) float32 {
+			if sensors.GetSensor("hungry").(bool) {
+				return 2.0
+			}
+			return 0.0
+		},
+	}
+	agent.actions = actions
+	SetState[bool](agent, 5, true)
+	SetSensor(agent, "hungry", false)
+
+	runner := CreateRunner(agent, PlanOptions{MaxDepth: 10})
+	if err := runner.Tick(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if runner.GetGoalName() != "stay_warm" || runner.GetPlan()[1].GetName() != "chop_wood" {
+		t.Errorf("Expected HTN plan for 'stay_warm', got '%s'", runner.GetGoalName())
+	}
+
+	SetSensor(agent, "hungry", true)
+	if err := runner.Tick(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if runner.GetGoalName() != "eat" || runner.GetPlan()[1].GetName() != "eat" {
+		t.Errorf("Expected GOAP plan for 'eat', got '%s'", runner.GetGoalName())
+	}
+}
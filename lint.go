@@ -0,0 +1,475 @@
+package goapai
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type lintKind uint8
+
+const (
+	// DUPLICATE_ACTION reports several actions with the same name, that cannot be told apart in a Plan.
+	DUPLICATE_ACTION lintKind = iota
+	// INVALID_OPERATOR reports a condition or an effect using an operator not allowed on its type,
+	// that never holds or fails to apply.
+	INVALID_OPERATOR
+	// DIVISION_BY_ZERO reports an effect dividing by zero.
+	DIVISION_BY_ZERO
+	// IMPOSSIBLE_CONDITIONS reports conditions on the same state that cannot hold together.
+	IMPOSSIBLE_CONDITIONS
+	// UNREACHABLE_GOAL reports a goal condition that no action can produce:
+	// the goal can only be achieved if the condition already holds in the world state.
+	UNREACHABLE_GOAL
+	// DEAD_ACTION reports an action whose effects are on states that no condition reads.
+	DEAD_ACTION
+	// UNUSED_EFFECT reports an effect on a state that no condition reads.
+	UNUSED_EFFECT
+)
+
+var lintKindNames = [...]string{
+	"duplicate action",
+	"invalid operator",
+	"division by zero",
+	"impossible conditions",
+	"unreachable goal",
+	"dead action",
+	"unused effect",
+}
+
+func (kind lintKind) String() string {
+	return lintKindNames[kind]
+}
+
+// LintIssue is a mistake found in the goals and actions by Lint.
+type LintIssue struct {
+	Kind    lintKind
+	Origin  string // Goal or action where the mistake was found
+	Message string
+}
+
+func (issue LintIssue) String() string {
+	return fmt.Sprintf("%s: %s: %s", issue.Origin, issue.Kind, issue.Message)
+}
+
+// LintReport lists the mistakes found by Lint.
+type LintReport []LintIssue
+
+// Err returns nil if the report is empty, or an error listing its issues and matching ErrValidation.
+func (report LintReport) Err() error {
+	errs := make([]error, 0, len(report))
+	for _, issue := range report {
+		errs = append(errs, fmt.Errorf("%w: %s", ErrValidation, issue))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Filter returns the issues of the report of the given kinds.
+func (report LintReport) Filter(kinds ...lintKind) LintReport {
+	filtered := LintReport{}
+	for _, issue := range report {
+		if slices.Contains(kinds, issue.Kind) {
+			filtered = append(filtered, issue)
+		}
+	}
+
+	return filtered
+}
+
+// Lint analyses the goals and the actions without planning, and reports the mistakes that are
+// otherwise only discovered at plan time, or never: duplicate action names, operators not allowed
+// on the type of a condition or an effect, divisions by zero, conditions that cannot hold together,
+// goal conditions that no action can produce, and effects on states that no condition reads.
+//
+// The analysis works on the states' keys and values, without the world state: a goal condition
+// that no action produces is reported, even if it already holds in the world state. The ConditionFn
+// and EffectFn are considered to read and write their Key only. The actions of the HTN tasks of
+// the goals, and the children of composite actions, are analysed as well. The actions bound from
+// templates are unknown before planning: if there are templates, the unreachable goals and the
+// unused effects are not reported. The states are named in the messages with the names registered in
+// the optional schema.
+//
+// Example, in the unit tests of the content:
+//
+//	if err := goapai.Lint(goals, actions).Err(); err != nil {
+//	    t.Error(err)
+//	}
+func Lint(goals Goals, actions Actions, schema ...*Schema) LintReport {
+	linter := &linter{visited: map[*Action]bool{}}
+	if len(schema) > 0 {
+		linter.schema = schema[0]
+	}
+
+	goalNames := make([]GoalName, 0, len(goals))
+	for name := range goals {
+		goalNames = append(goalNames, name)
+	}
+	slices.Sort(goalNames)
+
+	// The actions planned are the actions of the agent, and the actions of the HTN tasks
+	planned := slices.Clone(actions)
+	for _, name := range goalNames {
+		if task := goals[name].Task; task != nil {
+			planned = append(planned, taskActions(task, map[*Task]bool{})...)
+		}
+	}
+
+	linter.duplicates(actions)
+	for _, action := range planned {
+		linter.action(action)
+	}
+	for _, name := range goalNames {
+		linter.conditions(fmt.Sprintf("goal %q", name), goals[name].Conditions)
+	}
+
+	if !Actions(planned).hasTemplates() {
+		for _, name := range goalNames {
+			linter.reachability(fmt.Sprintf("goal %q", name), goals[name].Conditions, planned)
+		}
+		linter.usage(goals, actions, planned)
+	}
+
+	return linter.report
+}
+
+type linter struct {
+	schema  *Schema // Names of the states in the messages, optional
+	report  LintReport
+	visited map[*Action]bool
+}
+
+func (linter *linter) add(kind lintKind, origin string, format string, args ...any) {
+	linter.report = append(linter.report, LintIssue{Kind: kind, Origin: origin, Message: fmt.Sprintf(format, args...)})
+}
+
+func (linter *linter) duplicates(actions Actions) {
+	seen := map[string]bool{}
+	for _, action := range actions {
+		if seen[action.name] {
+			linter.add(DUPLICATE_ACTION, fmt.Sprintf("action %q", action.name), "the name is used by several actions")
+		}
+		seen[action.name] = true
+	}
+}
+
+// action checks the operators and the conditions of the action, and of its children.
+func (linter *linter) action(action *Action) {
+	if linter.visited[action] {
+		return
+	}
+	linter.visited[action] = true
+
+	origin := fmt.Sprintf("action %q", action.name)
+	linter.conditions(origin, action.conditions)
+	for _, effect := range action.effects {
+		linter.effect(origin, effect)
+	}
+
+	if action.composite != nil {
+		linter.duplicates(action.composite.children)
+		for _, child := range action.composite.children {
+			linter.action(child)
+		}
+		linter.conditions(origin+" sub-goal", action.composite.subGoal)
+		linter.duplicates(action.composite.subActions)
+		for _, subAction := range action.composite.subActions {
+			linter.action(subAction)
+		}
+	}
+}
+
+func (linter *linter) effect(origin string, effect EffectInterface) {
+	switch effect := effect.(type) {
+	case EffectBool:
+		if effect.Operator != SET {
+			linter.add(INVALID_OPERATOR, origin, "effect on state %s: operator %s is not allowed on a bool", linter.schema.FormatKey(effect.Key), effect.Operator)
+		}
+	case EffectString:
+		if effect.Operator != SET && effect.Operator != ADD {
+			linter.add(INVALID_OPERATOR, origin, "effect on state %s: operator %s is not allowed on a string", linter.schema.FormatKey(effect.Key), effect.Operator)
+		}
+	case numericEffect:
+		if operator := effect.arithmeticOperator(); operator > DIVIDE {
+			linter.add(INVALID_OPERATOR, origin, "effect on state %s: unknown operator %s", linter.schema.FormatKey(effect.GetKey()), operator)
+		} else if operator == DIVIDE && effect.isZero() {
+			linter.add(DIVISION_BY_ZERO, origin, "effect on state %s divides by zero", linter.schema.FormatKey(effect.GetKey()))
+		}
+	}
+}
+
+// conditions reports the conditions with an invalid operator, and the states whose conditions cannot hold together.
+func (linter *linter) conditions(origin string, conditions Conditions) {
+	byKey := map[StateKey][]comparableCondition{}
+	var keys []StateKey
+
+	for _, condition := range conditions {
+		compared, ok := condition.(comparableCondition)
+		if !ok {
+			continue
+		}
+		if !compared.validOperator() {
+			linter.add(INVALID_OPERATOR, origin, "condition on state %s: operator %s is not allowed on a %s",
+				linter.schema.FormatKey(condition.GetKey()), compared.comparisonOperator(), compared.valueKind())
+			continue
+		}
+
+		if _, ok := byKey[condition.GetKey()]; !ok {
+			keys = append(keys, condition.GetKey())
+		}
+		byKey[condition.GetKey()] = append(byKey[condition.GetKey()], compared)
+	}
+
+	for _, key := range keys {
+		group := byKey[key]
+		if slices.ContainsFunc(group, func(condition comparableCondition) bool { return condition.valueKind() != group[0].valueKind() }) {
+			linter.add(IMPOSSIBLE_CONDITIONS, origin, "state %s is compared with values of different types", linter.schema.FormatKey(key))
+			continue
+		}
+
+		// A value satisfying all the conditions is searched among the values around the compared ones
+		var candidates []any
+		for _, condition := range group {
+			candidates = append(candidates, condition.candidates(group)...)
+		}
+		satisfiable := slices.ContainsFunc(candidates, func(value any) bool {
+			for _, condition := range group {
+				if !condition.matches(value) {
+					return false
+				}
+			}
+			return true
+		})
+		if !satisfiable {
+			linter.add(IMPOSSIBLE_CONDITIONS, origin, "the conditions on state %s cannot hold together", linter.schema.FormatKey(key))
+		}
+	}
+}
+
+// reachability reports the goal conditions that no effect of the actions can produce.
+func (linter *linter) reachability(origin string, conditions Conditions, actions Actions) {
+	for _, condition := range conditions {
+		produced := slices.ContainsFunc(actions, func(action *Action) bool {
+			return slices.ContainsFunc(action.effects, func(effect EffectInterface) bool {
+				return canProduce(effect, condition)
+			})
+		})
+		if !produced {
+			linter.add(UNREACHABLE_GOAL, origin, "no action produces the condition on state %s", linter.schema.FormatKey(condition.GetKey()))
+		}
+	}
+}
+
+// usage reports the actions and the effects on states that no condition of the goals and the planned actions reads.
+func (linter *linter) usage(goals Goals, actions Actions, planned Actions) {
+	read := map[StateKey]bool{}
+	for _, goal := range goals {
+		for _, condition := range goal.Conditions {
+			read[condition.GetKey()] = true
+		}
+		if goal.Task != nil {
+			for _, conditions := range taskConditions(goal.Task, map[*Task]bool{}) {
+				for _, condition := range conditions {
+					read[condition.GetKey()] = true
+				}
+			}
+		}
+	}
+	for _, action := range planned {
+		for _, condition := range action.conditions {
+			read[condition.GetKey()] = true
+		}
+		if action.composite != nil {
+			for _, condition := range action.composite.subGoal {
+				read[condition.GetKey()] = true
+			}
+		}
+	}
+
+	for _, action := range actions {
+		if action.template != nil || len(action.effects) == 0 {
+			continue
+		}
+
+		var unused []StateKey
+		for _, effect := range action.effects {
+			if !read[effect.GetKey()] {
+				unused = append(unused, effect.GetKey())
+			}
+		}
+
+		origin := fmt.Sprintf("action %q", action.name)
+		if len(unused) == len(action.effects) {
+			linter.add(DEAD_ACTION, origin, "no condition reads its effects")
+			continue
+		}
+		for _, key := range unused {
+			linter.add(UNUSED_EFFECT, origin, "no condition reads state %s", linter.schema.FormatKey(key))
+		}
+	}
+}
+
+// canProduce returns true if the effect can make the condition hold, in at least one world state.
+func canProduce(effect EffectInterface, condition ConditionInterface) bool {
+	if effect.GetKey() != condition.GetKey() {
+		return false
+	}
+
+	switch condition := condition.(type) {
+	case *ConditionBool:
+		effect, ok := effect.(EffectBool)
+		return !ok || condition.compare(effect.Value)
+	case *ConditionString:
+		effect, ok := effect.(EffectString)
+		return !ok || effect.Operator == ADD || condition.compare(effect.Value)
+	}
+
+	return true
+}
+
+// taskActions returns the actions of task, and of its subtasks.
+func taskActions(task *Task, visited map[*Task]bool) Actions {
+	if visited[task] {
+		return nil
+	}
+	visited[task] = true
+
+	switch task.kind {
+	case primitiveTask:
+		return Actions{task.action}
+	case goalTask:
+		return task.actions
+	}
+
+	actions := Actions{}
+	for _, method := range task.methods {
+		for _, subtask := range method.Subtasks {
+			actions = append(actions, taskActions(subtask, visited)...)
+		}
+	}
+
+	return actions
+}
+
+// taskConditions returns the conditions of the methods and goal tasks of task, and of its subtasks.
+func taskConditions(task *Task, visited map[*Task]bool) []Conditions {
+	if visited[task] {
+		return nil
+	}
+	visited[task] = true
+
+	switch task.kind {
+	case primitiveTask:
+		return nil
+	case goalTask:
+		return []Conditions{task.goal}
+	}
+
+	var conditions []Conditions
+	for _, method := range task.methods {
+		conditions = append(conditions, method.Conditions)
+		for _, subtask := range method.Subtasks {
+			conditions = append(conditions, taskConditions(subtask, visited)...)
+		}
+	}
+
+	return conditions
+}
+
+// comparableCondition is implemented by the conditions comparing a state with a value.
+type comparableCondition interface {
+	ConditionInterface
+	valueKind() stateKind
+	comparisonOperator() operator
+	validOperator() bool
+	candidates(group []comparableCondition) []any // Values around the compared values of the group
+	matches(value any) bool
+}
+
+func (condition *Condition[T]) comparisonOperator() operator {
+	return condition.Operator
+}
+
+func (condition *Condition[T]) validOperator() bool {
+	return condition.Operator <= UPPER
+}
+
+func (condition *Condition[T]) candidates(group []comparableCondition) []any {
+	candidates := []any{condition.Value - 1, condition.Value, condition.Value + 1}
+
+	// The middle values are required for floating-point intervals, e.g. > 1 and < 1.5
+	for _, other := range group {
+		if other, ok := other.(*Condition[T]); ok {
+			candidates = append(candidates, condition.Value/2+other.Value/2)
+		}
+	}
+
+	return candidates
+}
+
+func (condition *Condition[T]) matches(value any) bool {
+	v, ok := value.(T)
+
+	return ok && condition.compare(v)
+}
+
+func (conditionBool *ConditionBool) comparisonOperator() operator {
+	return conditionBool.Operator
+}
+
+func (conditionBool *ConditionBool) validOperator() bool {
+	return conditionBool.Operator == EQUAL || conditionBool.Operator == NOT_EQUAL
+}
+
+func (conditionBool *ConditionBool) candidates([]comparableCondition) []any {
+	return []any{true, false}
+}
+
+func (conditionBool *ConditionBool) matches(value any) bool {
+	v, ok := value.(bool)
+
+	return ok && conditionBool.compare(v)
+}
+
+func (conditionString *ConditionString) comparisonOperator() operator {
+	return conditionString.Operator
+}
+
+func (conditionString *ConditionString) validOperator() bool {
+	return conditionString.Operator == EQUAL || conditionString.Operator == NOT_EQUAL
+}
+
+func (conditionString *ConditionString) candidates(group []comparableCondition) []any {
+	// A value different from all the compared values satisfies the NOT_EQUAL conditions
+	var other strings.Builder
+	for _, condition := range group {
+		if condition, ok := condition.(*ConditionString); ok {
+			other.WriteString(condition.Value)
+		}
+	}
+	other.WriteString("_")
+
+	return []any{conditionString.Value, other.String()}
+}
+
+func (conditionString *ConditionString) matches(value any) bool {
+	v, ok := value.(string)
+
+	return ok && conditionString.compare(v)
+}
+
+// numericEffect is implemented by the Effect of the numeric types.
+type numericEffect interface {
+	EffectInterface
+	arithmeticOperator() arithmetic
+	isZero() bool
+}
+
+func (effect Effect[T]) arithmeticOperator() arithmetic {
+	return effect.Operator
+}
+
+func (effect Effect[T]) isZero() bool {
+	return effect.Value == 0
+}
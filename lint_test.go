@@ -0,0 +1,230 @@
+package goapai
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	always := func(sensors Sensors) float32 { return 1 }
+
+	tests := []struct {
+		name     string
+		goals    Goals
+		actions  func() Actions
+		expected []LintIssue
+	}{
+		{
+			name: "valid domain",
+			goals: Goals{"warm": {Conditions: Conditions{
+				&Condition[int]{Key: 1, Value: 20, Operator: UPPER_OR_EQUAL},
+				&Condition[int]{Key: 1, Value: 30, Operator: LOWER},
+			}, PriorityFn: always}},
+			actions: func() Actions {
+				actions := Actions{}
+				actions.AddAction("get_wood", 1, true, Conditions{}, Effects{EffectBool{Key: 2, Value: true, Operator: SET}})
+				actions.AddAction("burn", 1, true, Conditions{&ConditionBool{Key: 2, Value: true, Operator: EQUAL}}, Effects{
+					Effect[int]{Key: 1, Value: 5, Operator: ADD},
+					EffectBool{Key: 2, Value: false, Operator: SET},
+				})
+				return actions
+			},
+		},
+		{
+			name:  "duplicate action",
+			goals: Goals{"warm": {Conditions: Conditions{&ConditionBool{Key: 1, Value: true, Operator: EQUAL}}, PriorityFn: always}},
+			actions: func() Actions {
+				actions := Actions{}
+				actions.AddAction("burn", 1, false, Conditions{}, Effects{EffectBool{Key: 1, Value: true, Operator: SET}})
+				actions.AddAction("burn", 2, false, Conditions{}, Effects{EffectBool{Key: 1, Value: true, Operator: SET}})
+				return actions
+			},
+			expected: []LintIssue{{Kind: DUPLICATE_ACTION, Origin: `action "burn"`}},
+		},
+		{
+			name: "invalid operators",
+			goals: Goals{"warm": {Conditions: Conditions{
+				&ConditionBool{Key: 1, Value: true, Operator: UPPER},
+				&ConditionString{Key: 2, Value: "lit", Operator: EQUAL},
+			}, PriorityFn: always}},
+			actions: func() Actions {
+				actions := Actions{}
+				actions.AddAction("burn", 1, false, Conditions{}, Effects{
+					EffectBool{Key: 1, Value: true, Operator: ADD},
+					EffectString{Key: 2, Value: "lit", Operator: MULTIPLY},
+					Effect[float64]{Key: 1, Value: 0, Operator: DIVIDE},
+				})
+				return actions
+			},
+			expected: []LintIssue{
+				{Kind: INVALID_OPERATOR, Origin: `action "burn"`},
+				{Kind: INVALID_OPERATOR, Origin: `action "burn"`},
+				{Kind: DIVISION_BY_ZERO, Origin: `action "burn"`},
+				{Kind: INVALID_OPERATOR, Origin: `goal "warm"`},
+			},
+		},
+		{
+			name: "impossible conditions",
+			goals: Goals{"warm": {Conditions: Conditions{
+				&Condition[float64]{Key: 1, Value: 1, Operator: UPPER},
+				&Condition[float64]{Key: 1, Value: 1.5, Operator: LOWER},
+				&ConditionString{Key: 2, Value: "lit", Operator: EQUAL},
+				&ConditionString{Key: 2, Value: "out", Operator: EQUAL},
+			}, PriorityFn: always}},
+			actions: func() Actions {
+				actions := Actions{}
+				actions.AddAction("burn", 1, false, Conditions{
+					&Condition[int]{Key: 3, Value: 2, Operator: UPPER},
+					&Condition[int]{Key: 3, Value: 3, Operator: LOWER},
+					&ConditionBool{Key: 4, Value: true, Operator: EQUAL},
+					&Condition[int]{Key: 4, Value: 1, Operator: EQUAL},
+				}, Effects{
+					Effect[float64]{Key: 1, Value: 1.2, Operator: SET},
+					EffectString{Key: 2, Value: "lit", Operator: ADD},
+				})
+				return actions
+			},
+			expected: []LintIssue{
+				{Kind: IMPOSSIBLE_CONDITIONS, Origin: `action "burn"`},
+				{Kind: IMPOSSIBLE_CONDITIONS, Origin: `action "burn"`},
+				{Kind: IMPOSSIBLE_CONDITIONS, Origin: `goal "warm"`},
+			},
+		},
+		{
+			name: "unreachable goal",
+			goals: Goals{"warm": {Conditions: Conditions{
+				&ConditionBool{Key: 1, Value: true, Operator: EQUAL},
+				&Condition[int]{Key: 2, Value: 20, Operator: UPPER_OR_EQUAL},
+			}, PriorityFn: always}},
+			actions: func() Actions {
+				actions := Actions{}
+				actions.AddAction("extinguish", 1, false, Conditions{}, Effects{EffectBool{Key: 1, Value: false, Operator: SET}})
+				return actions
+			},
+			expected: []LintIssue{
+				{Kind: UNREACHABLE_GOAL, Origin: `goal "warm"`},
+				{Kind: UNREACHABLE_GOAL, Origin: `goal "warm"`},
+			},
+		},
+		{
+			name:  "dead action and unused effect",
+			goals: Goals{"warm": {Conditions: Conditions{&ConditionBool{Key: 1, Value: true, Operator: EQUAL}}, PriorityFn: always}},
+			actions: func() Actions {
+				actions := Actions{}
+				actions.AddAction("burn", 1, false, Conditions{}, Effects{
+					EffectBool{Key: 1, Value: true, Operator: SET},
+					Effect[int]{Key: 2, Value: 1, Operator: ADD},
+				})
+				actions.AddAction("dance", 1, false, Conditions{}, Effects{EffectBool{Key: 3, Value: true, Operator: SET}})
+				return actions
+			},
+			expected: []LintIssue{
+				{Kind: UNUSED_EFFECT, Origin: `action "burn"`},
+				{Kind: DEAD_ACTION, Origin: `action "dance"`},
+			},
+		},
+		{
+			name: "htn task actions",
+			goals: Goals{"warm": {
+				Conditions: Conditions{&ConditionBool{Key: 1, Value: true, Operator: EQUAL}},
+				PriorityFn: always,
+				Task: CreatePrimitiveTask(&Action{name: "burn", effects: Effects{
+					EffectBool{Key: 1, Value: true, Operator: SET},
+				}}),
+			}},
+			actions: func() Actions { return Actions{} },
+		},
+		{
+			name:  "templates skip the reachability",
+			goals: Goals{"warm": {Conditions: Conditions{&ConditionBool{Key: 1, Value: true, Operator: EQUAL}}, PriorityFn: always}},
+			actions: func() Actions {
+				actions := Actions{}
+				AddActionTemplate(&actions, "light", 1, false,
+					func(sensors Sensors, view WorldView) []StateKey { return []StateKey{1} },
+					func(key StateKey) (Conditions, Effects) {
+						return Conditions{}, Effects{EffectBool{Key: key, Value: true, Operator: SET}}
+					},
+				)
+				return actions
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			report := Lint(test.goals, test.actions())
+
+			if len(report) != len(test.expected) {
+				t.Fatalf("Expected %d issues, got %d: %v", len(test.expected), len(report), report)
+			}
+			for i, issue := range report {
+				if issue.Kind != test.expected[i].Kind || issue.Origin != test.expected[i].Origin {
+					t.Errorf("Expected issue %s of %s, got %s", test.expected[i].Kind, test.expected[i].Origin, issue)
+				}
+			}
+
+			if err := report.Err(); (err != nil) != (len(test.expected) > 0) || (err != nil && !errors.Is(err, ErrValidation)) {
+				t.Errorf("Expected an error matching ErrValidation for %d issues, got %v", len(test.expected), err)
+			}
+		})
+	}
+}
+
+func TestLint_Schema(t *testing.T) {
+	schema := CreateSchema()
+	if err := RegisterNamedState[int](schema, 1, "wood"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	goals := Goals{"warm": {Conditions: Conditions{
+		&Condition[int]{Key: 1, Value: 5, Operator: UPPER_OR_EQUAL},
+		&ConditionBool{Key: 2, Value: true, Operator: EQUAL},
+	}}}
+
+	tests := []struct {
+		name     string
+		schema   *Schema
+		expected []string
+	}{
+		{
+			name:     "without schema",
+			expected: []string{"no action produces the condition on state 1", "no action produces the condition on state 2"},
+		},
+		{
+			name:     "named states",
+			schema:   schema,
+			expected: []string{`no action produces the condition on state "wood"`, "no action produces the condition on state 2"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			report := Lint(goals, Actions{}, test.schema)
+
+			if len(report) != len(test.expected) {
+				t.Fatalf("Expected %d issues, got %d: %v", len(test.expected), len(report), report)
+			}
+			for i, issue := range report {
+				if issue.Message != test.expected[i] {
+					t.Errorf("Expected the message %q, got %q", test.expected[i], issue.Message)
+				}
+			}
+		})
+	}
+}
+
+func TestLintReport_Filter(t *testing.T) {
+	report := LintReport{
+		{Kind: DEAD_ACTION, Origin: `action "dance"`},
+		{Kind: UNREACHABLE_GOAL, Origin: `goal "warm"`},
+		{Kind: UNUSED_EFFECT, Origin: `action "burn"`},
+	}
+
+	filtered := report.Filter(UNREACHABLE_GOAL, UNUSED_EFFECT)
+	if len(filtered) != 2 || filtered[0].Kind != UNREACHABLE_GOAL || filtered[1].Kind != UNUSED_EFFECT {
+		t.Errorf("Expected the unreachable goal and the unused effect, got %v", filtered)
+	}
+	if err := report.Filter(DUPLICATE_ACTION).Err(); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
@@ -1,11 +1,100 @@
 package goapai
 
-import "fmt"
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
 
 type Plan Actions
 
+type direction uint8
+
+const (
+	// FORWARD searches from the current world state towards the goal.
+	FORWARD direction = iota
+	// BACKWARD searches from the goal's conditions towards the current world state.
+	BACKWARD
+)
+
+// PlanOptions configures a planning request.
+type PlanOptions struct {
+	MaxDepth   int             // Maximum number of actions required to match the goal
+	MaxNodes   int             // Maximum number of expanded nodes, 0 means unlimited
+	MaxOpenSet int             // Maximum size of the open set, bounding the memory used by the search, 0 means unlimited
+	Deadline   time.Time       // Wall-clock time after which the search is aborted, zero means no deadline
+	Context    context.Context // Aborts the search once done, nil means no cancellation
+	Direction  direction       // Search direction, FORWARD by default. BACKWARD rejects the ConditionFn with a WorldFn
+
+	// PartialPlan returns the best partial plan when the search is aborted by a budget (MaxNodes,
+	// MaxOpenSet, Deadline or Context): the plan leading to the world state closest to the goal,
+	// according to the heuristic. PlanResult.Incomplete is then true. Forward search only.
+	PartialPlan bool
+
+	// Fallback tries all the goals with a priority above zero, by descending priority,
+	// until one of them can be achieved.
+	Fallback bool
+	// UtilityFn plans the UtilityCandidates goals with the highest priorities, and selects
+	// the one with the highest utility. It takes precedence over Fallback.
+	UtilityFn         GoalUtilityFn
+	UtilityCandidates int           // Number of goals planned with UtilityFn, 0 means all the goals
+	GoalMaxNodes      int           // Maximum number of expanded nodes per goal with Fallback or UtilityFn, 0 means unlimited
+	GoalTimeout       time.Duration // Maximum planning time per goal with Fallback or UtilityFn, 0 means unlimited
+
+	// Trace records the nodes explored by the forward searches, for debugging. Nil means no recording.
+	Trace *SearchTrace
+
+	// Cache returns the plans already found for the same goal, actions and world state, and caches the
+	// plans found. Nil means no cache. See PlanCache.
+	Cache *PlanCache
+
+	// Repair makes the Runner repair its plan with RepairPlan when the conditions of the next action
+	// do not hold anymore, rather than planning the goal again from scratch.
+	Repair bool
+
+	deadline time.Time
+}
+
+// SearchStats holds the diagnostics collected during a planning request.
+type SearchStats struct {
+	NodesExpanded  int           // Number of nodes popped from the open set and expanded
+	NodesGenerated int           // Number of new nodes pushed to the open set
+	MaxOpenSet     int           // Largest size reached by the open set
+	Elapsed        time.Duration // Wall-clock time spent planning
+}
+
+// PlanResult is the outcome of FindPlan: the selected goal, its plan and the search statistics.
+type PlanResult struct {
+	GoalName GoalName
+	Plan     Plan
+	Skipped  []SkippedGoal // Goals tried before GoalName in Fallback mode
+	// Incomplete is true if Plan is a partial plan, not matching the goal. See PlanOptions.PartialPlan.
+	Incomplete bool
+	// Repaired is true if Plan is the repair of a previous plan, rather than planned from scratch. See RepairPlan.
+	Repaired bool
+	SearchStats
+}
+
+// SkippedGoal is a goal that could not be achieved in Fallback mode, and the reason why.
+type SkippedGoal struct {
+	GoalName GoalName
+	Priority float32
+	Err      error
+}
+
 type GoalPriorityFn func(sensors Sensors) float32
 
+// GoalUtilityFn scores a goal from its priority, and the total cost and number of actions of its plan.
+type GoalUtilityFn func(priority float32, cost float32, length int) float32
+
+// UtilityPriorityPerCost is a GoalUtilityFn favoring the goals with the best ratio between priority and cost.
+func UtilityPriorityPerCost(priority float32, cost float32, length int) float32 {
+	return priority / (1 + cost)
+}
+
 // GetTotalCost returns the cost of Plan.
 //
 // This total cost is the sum of all actions's cost.
@@ -19,26 +108,153 @@ func (plan Plan) GetTotalCost() float32 {
 	return cost
 }
 
+// steps returns the actions of the plan to perform, without its starting node.
+func (plan Plan) steps() Plan {
+	if len(plan) > 0 && plan[0].start {
+		return plan[1:]
+	}
+
+	return plan
+}
+
 // GetPlan returns the current GoalName, and the best Plan to achieve this Goal.
 //
 // The maxDepth argument limits the number of actions required to match the goal.
 // Plan can be empty if the number of actions required is upper than maxDepth, or if the goal is unreachable.
 func GetPlan(agent Agent, maxDepth int) (GoalName, Plan) {
-	goalName, err := agent.getPrioritizedGoalName()
+	result, _ := FindPlan(agent, PlanOptions{MaxDepth: maxDepth})
+
+	return result.GoalName, result.Plan
+}
+
+// FindPlan returns the PlanResult for the current prioritized goal.
+//
+// Contrary to GetPlan, the reason of a failure is returned as an error, which can be
+// matched with errors.Is against ErrNoGoalAvailable, ErrGoalUnreachable, ErrMaxDepth,
+// ErrTypeMismatch, ErrNodeBudget, ErrOpenSetBudget, ErrDeadline or ErrCanceled. The search
+// statistics are filled in both cases.
+//
+// With options.PartialPlan, a search aborted by a budget still returns its error, along with the
+// best partial plan found so far and PlanResult.Incomplete set to true.
+//
+// With options.Fallback, the goals are tried by descending priority, and the first one
+// achievable is returned. The goals tried before are listed in PlanResult.Skipped.
+//
+// With options.UtilityFn, the goals with the highest priorities are all planned, and the one
+// with the highest utility is returned. The goals that could not be achieved are listed in
+// PlanResult.Skipped.
+//
+// FindPlan runs the whole planning at once. To spread it over several frames, use a Planner.
+func FindPlan(agent Agent, options PlanOptions) (PlanResult, error) {
+	return CreatePlanner(agent, options).run()
+}
+
+// goalOptions returns the options to plan a single goal with Fallback or UtilityFn.
+// Partial plans are not returned for a single goal: the goal is skipped instead.
+func (options PlanOptions) goalOptions() PlanOptions {
+	options.PartialPlan = false
+	if options.GoalMaxNodes > 0 && (options.MaxNodes == 0 || options.GoalMaxNodes < options.MaxNodes) {
+		options.MaxNodes = options.GoalMaxNodes
+	}
+	if options.GoalTimeout > 0 {
+		options.deadline = time.Now().Add(options.GoalTimeout)
+	}
+
+	return options
+}
 
-	if err != nil {
-		fmt.Println(err)
+// addStats sums the statistics of a goal's search into stats.
+func (stats *SearchStats) addStats(other SearchStats) {
+	stats.NodesExpanded += other.NodesExpanded
+	stats.NodesGenerated += other.NodesGenerated
+	stats.MaxOpenSet = max(stats.MaxOpenSet, other.MaxOpenSet)
+}
+
+// createSearch creates the A* search for the goal, in the direction set by options,
+// or the decomposition of its Task. With options.Cache, the plan cached for the goal is
+// returned without any search, or the plan found is cached.
+func (agent *Agent) createSearch(goalName GoalName, options PlanOptions) searcher {
+	options.Trace.startGoal(goalName)
+	if options.Cache == nil {
+		return agent.createGoalSearch(goalName, options)
+	}
+
+	key := createPlanCacheKey(agent.w, goalName, agent.goals[goalName], agent.actions, options)
+	if plan, ok := options.Cache.get(key, agent.w, agent.actions); ok {
+		return cachedSearch{plan: plan}
+	}
 
-		return "", Plan{}
+	return &cachingSearch{
+		searcher: agent.createGoalSearch(goalName, options),
+		cache:    options.Cache,
+		key:      key,
+		world:    agent.w,
+		actions:  agent.actions,
 	}
+}
 
-	for _, state := range agent.w.states {
-		state.Store(&agent.w)
+// createGoalSearch creates the search for the goal, without cache.
+func (agent *Agent) createGoalSearch(goalName GoalName, options PlanOptions) searcher {
+	if agent.goals[goalName].Task != nil {
+		return createHTNSearch(agent.w, agent.goals[goalName], options)
+	}
+	if options.Direction == BACKWARD {
+		return createBackwardSearch(agent.w, agent.goals[goalName], agent.actions, options)
 	}
 
-	return goalName, astar(agent.w, agent.goals[goalName], agent.actions, maxDepth)
+	return createForwardSearch(agent.w, agent.goals[goalName], agent.actions, options)
 }
 
+// isAchievable returns true if a plan is found for the goal from the agent's world state,
+// with the options of a single goal of Fallback.
+func (agent *Agent) isAchievable(goalName GoalName, options PlanOptions) bool {
+	options.Trace = nil
+	planning := *agent
+	planning.w = agent.planningWorld()
+
+	search := planning.createSearch(goalName, options.goalOptions())
+	for !search.expand() {
+	}
+	_, _, err := search.result()
+
+	return err == nil
+}
+
+// checkBudget returns an error if the search went over the nodes, memory or time budget of options.
+func (options PlanOptions) checkBudget(stats SearchStats) error {
+	if options.MaxNodes > 0 && stats.NodesExpanded >= options.MaxNodes {
+		return ErrNodeBudget
+	}
+	if options.MaxOpenSet > 0 && stats.MaxOpenSet > options.MaxOpenSet {
+		return ErrOpenSetBudget
+	}
+	if !options.deadline.IsZero() && time.Now().After(options.deadline) {
+		return ErrDeadline
+	}
+
+	return options.checkInterruption()
+}
+
+// checkInterruption returns an error if the Deadline of options is exceeded, or if its Context is done.
+// Contrary to the per-goal budgets, an interruption aborts the whole planning request.
+func (options PlanOptions) checkInterruption() error {
+	if !options.Deadline.IsZero() && time.Now().After(options.Deadline) {
+		return ErrDeadline
+	}
+	if options.Context != nil {
+		if err := options.Context.Err(); err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return fmt.Errorf("%w: %w", ErrDeadline, err)
+			}
+			return fmt.Errorf("%w: %w", ErrCanceled, err)
+		}
+	}
+
+	return nil
+}
+
+// getPrioritizedGoalName returns the goal with the highest priority, above zero.
+// Goals with the same priority are ordered by name, as with getSortedGoals.
 func (agent *Agent) getPrioritizedGoalName() (GoalName, error) {
 	var prioritizedGoalName GoalName
 	var prioritizedValue float32
@@ -46,7 +262,7 @@ func (agent *Agent) getPrioritizedGoalName() (GoalName, error) {
 	for name, goal := range agent.goals {
 		priority := goal.PriorityFn(agent.sensors)
 
-		if priority > prioritizedValue {
+		if priority > prioritizedValue || (priority > 0.0 && priority == prioritizedValue && name < prioritizedGoalName) {
 			prioritizedGoalName = name
 			prioritizedValue = priority
 		}
@@ -55,6 +271,34 @@ func (agent *Agent) getPrioritizedGoalName() (GoalName, error) {
 	if prioritizedValue > 0.0 {
 		return prioritizedGoalName, nil
 	} else {
-		return prioritizedGoalName, fmt.Errorf("no goal available")
+		return prioritizedGoalName, ErrNoGoalAvailable
+	}
+}
+
+type prioritizedGoal struct {
+	name     GoalName
+	priority float32
+}
+
+// getSortedGoals returns the goals with a priority above zero, sorted by descending priority.
+// Goals with the same priority are sorted by name, so that the order is deterministic.
+func (agent *Agent) getSortedGoals() []prioritizedGoal {
+	goals := make([]prioritizedGoal, 0, len(agent.goals))
+
+	for name, goal := range agent.goals {
+		priority := goal.PriorityFn(agent.sensors)
+
+		if priority > 0.0 {
+			goals = append(goals, prioritizedGoal{name: name, priority: priority})
+		}
 	}
+
+	slices.SortFunc(goals, func(a, b prioritizedGoal) int {
+		if c := cmp.Compare(b.priority, a.priority); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.name, b.name)
+	})
+
+	return goals
 }
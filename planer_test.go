@@ -1,6 +1,11 @@
 package goapai
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
 
 func TestPlan_GetTotalCost(t *testing.T) {
 	tests := []struct {
@@ -255,6 +260,27 @@ func TestGetPrioritizedGoalName_ZeroPriority(t *testing.T) {
 	}
 }
 
+func TestGetPrioritizedGoalName_SamePriority(t *testing.T) {
+	goals := Goals{}
+	for _, name := range []GoalName{"gather", "build", "explore", "attack"} {
+		goals[name] = goalInterface{
+			Conditions: Conditions{},
+			PriorityFn: func(sensors Sensors) float32 {
+				return 1.0
+			},
+		}
+	}
+
+	agent := CreateAgent(goals, Actions{})
+
+	// The goals with the same priority are ordered by name, whatever the map order
+	for i := 0; i < 20; i++ {
+		if goalName, err := agent.getPrioritizedGoalName(); err != nil || goalName != "attack" {
+			t.Fatalf("Expected 'attack', got '%s', %v", goalName, err)
+		}
+	}
+}
+
 func TestGetPrioritizedGoalName_UsingSensors(t *testing.T) {
 	type Entity struct {
 		health int
@@ -360,3 +386,458 @@ func TestGetPlan_ComplexScenario(t *testing.T) {
 		t.Errorf("Expected total cost 4.0, got %f", totalCost)
 	}
 }
+
+// Test FindPlan
+func TestFindPlan_Stats(t *testing.T) {
+	actions := Actions{}
+	actions.AddAction("increment", 1.0, true, Conditions{}, Effects{
+		Effect[int]{Key: 1, Value: 10, Operator: ADD},
+	})
+
+	goals := Goals{
+		"reach_30": {
+			Conditions: Conditions{
+				&Condition[int]{Key: 1, Value: 30, Operator: EQUAL},
+			},
+			PriorityFn: func(sensors Sensors) float32 {
+				return 1.0
+			},
+		},
+	}
+
+	agent := CreateAgent(goals, actions)
+	SetState[int](&agent, 1, 0)
+
+	result, err := FindPlan(agent, PlanOptions{MaxDepth: 10})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if result.GoalName != "reach_30" {
+		t.Errorf("Expected goal 'reach_30', got '%s'", result.GoalName)
+	}
+	if len(result.Plan) != 4 {
+		t.Errorf("Expected plan with 4 actions (including root), got %d", len(result.Plan))
+	}
+	if result.NodesExpanded != 3 {
+		t.Errorf("Expected 3 expanded nodes, got %d", result.NodesExpanded)
+	}
+	if result.NodesGenerated != 3 {
+		t.Errorf("Expected 3 generated nodes, got %d", result.NodesGenerated)
+	}
+	if result.MaxOpenSet < 1 {
+		t.Errorf("Expected max open set of at least 1, got %d", result.MaxOpenSet)
+	}
+	if result.Elapsed <= 0 {
+		t.Error("Expected elapsed time to be measured")
+	}
+}
+
+func TestFindPlan_Errors(t *testing.T) {
+	increment := func() Actions {
+		actions := Actions{}
+		actions.AddAction("increment", 1.0, true, Conditions{}, Effects{
+			Effect[int]{Key: 1, Value: 1, Operator: ADD},
+		})
+		return actions
+	}
+
+	tests := []struct {
+		name     string
+		actions  Actions
+		priority float32
+		state    func(agent *Agent)
+		options  PlanOptions
+		wantErr  error
+	}{
+		{
+			name:     "no goal available",
+			actions:  increment(),
+			priority: 0.0,
+			state:    func(agent *Agent) { SetState[int](agent, 1, 0) },
+			options:  PlanOptions{MaxDepth: 10},
+			wantErr:  ErrNoGoalAvailable,
+		},
+		{
+			name:     "goal unreachable",
+			actions:  Actions{},
+			priority: 1.0,
+			state:    func(agent *Agent) { SetState[int](agent, 1, 0) },
+			options:  PlanOptions{MaxDepth: 10},
+			wantErr:  ErrGoalUnreachable,
+		},
+		{
+			name:     "depth limit",
+			actions:  increment(),
+			priority: 1.0,
+			state:    func(agent *Agent) { SetState[int](agent, 1, 0) },
+			options:  PlanOptions{MaxDepth: 5},
+			wantErr:  ErrMaxDepth,
+		},
+		{
+			name:     "type mismatch",
+			actions:  increment(),
+			priority: 1.0,
+			state:    func(agent *Agent) { SetState[bool](agent, 1, false) },
+			options:  PlanOptions{MaxDepth: 10},
+			wantErr:  ErrTypeMismatch,
+		},
+		{
+			name:     "node budget",
+			actions:  increment(),
+			priority: 1.0,
+			state:    func(agent *Agent) { SetState[int](agent, 1, 0) },
+			options:  PlanOptions{MaxDepth: 200, MaxNodes: 10},
+			wantErr:  ErrNodeBudget,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			priority := tt.priority
+			goals := Goals{
+				"reach_100": {
+					Conditions: Conditions{
+						&Condition[int]{Key: 1, Value: 100, Operator: EQUAL},
+					},
+					PriorityFn: func(sensors Sensors) float32 {
+						return priority
+					},
+				},
+			}
+
+			agent := CreateAgent(goals, tt.actions)
+			tt.state(&agent)
+
+			result, err := FindPlan(agent, tt.options)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if len(result.Plan) != 0 {
+				t.Errorf("Expected empty plan, got %d actions", len(result.Plan))
+			}
+		})
+	}
+}
+
+// createBudgetAgent creates an agent with a goal far away, reached through several increments.
+func createBudgetAgent() Agent {
+	actions := Actions{}
+	actions.AddAction("increment_1", 1.0, true, Conditions{}, Effects{
+		Effect[int]{Key: 1, Value: 1, Operator: ADD},
+	})
+	actions.AddAction("increment_2", 2.0, true, Conditions{}, Effects{
+		Effect[int]{Key: 1, Value: 2, Operator: ADD},
+	})
+	actions.AddAction("increment_3", 3.0, true, Conditions{}, Effects{
+		Effect[int]{Key: 1, Value: 3, Operator: ADD},
+	})
+
+	goals := Goals{
+		"reach_1000": {
+			Conditions: Conditions{
+				&Condition[int]{Key: 1, Value: 1000, Operator: EQUAL},
+			},
+			PriorityFn: func(sensors Sensors) float32 {
+				return 1.0
+			},
+		},
+	}
+
+	agent := CreateAgent(goals, actions)
+	SetState[int](&agent, 1, 0)
+
+	return agent
+}
+
+func TestFindPlan_Budgets(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+
+	tests := []struct {
+		name    string
+		options PlanOptions
+		wantErr []error
+	}{
+		{"open set budget", PlanOptions{MaxDepth: 2000, MaxOpenSet: 20}, []error{ErrOpenSetBudget}},
+		{"deadline", PlanOptions{MaxDepth: 2000, Deadline: time.Now().Add(-time.Second)}, []error{ErrDeadline}},
+		{"context canceled", PlanOptions{MaxDepth: 2000, Context: canceled}, []error{ErrCanceled, context.Canceled}},
+		{"context deadline", PlanOptions{MaxDepth: 2000, Context: expired}, []error{ErrDeadline, context.DeadlineExceeded}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := FindPlan(createBudgetAgent(), tt.options)
+			for _, wantErr := range tt.wantErr {
+				if !errors.Is(err, wantErr) {
+					t.Errorf("Expected error %v, got %v", wantErr, err)
+				}
+			}
+			if len(result.Plan) != 0 || result.Incomplete {
+				t.Errorf("Expected empty plan, got %d actions", len(result.Plan))
+			}
+		})
+	}
+}
+
+func TestFindPlan_PartialPlan(t *testing.T) {
+	agent := createBudgetAgent()
+
+	result, err := FindPlan(agent, PlanOptions{MaxDepth: 2000, MaxNodes: 10, PartialPlan: true})
+	if !errors.Is(err, ErrNodeBudget) {
+		t.Fatalf("Expected ErrNodeBudget, got %v", err)
+	}
+	if !result.Incomplete {
+		t.Error("Expected result to be incomplete")
+	}
+	if len(result.Plan) < 2 {
+		t.Fatalf("Expected a partial plan, got %d actions", len(result.Plan))
+	}
+
+	// The partial plan moves towards the goal
+	w, err := result.Plan.replay(agent.w.clone(), nil)
+	if err != nil {
+		t.Fatal("Expected the partial plan to be valid")
+	}
+	if value, _ := lookupState[int](&w, 1); value <= 0 {
+		t.Errorf("Expected the partial plan to increase state 1, got %d", value)
+	}
+
+	// Aborted before any expansion, the partial plan is empty
+	result, err = FindPlan(agent, PlanOptions{MaxDepth: 2000, Deadline: time.Now().Add(-time.Second), PartialPlan: true})
+	if !errors.Is(err, ErrDeadline) || result.Incomplete || len(result.Plan) != 0 {
+		t.Errorf("Expected an empty complete result and ErrDeadline, got %d actions, %v", len(result.Plan), err)
+	}
+
+	// Partial plans are not returned by the backward search
+	result, err = FindPlan(agent, PlanOptions{MaxDepth: 2000, MaxNodes: 10, PartialPlan: true, Direction: BACKWARD})
+	if !errors.Is(err, ErrNodeBudget) || result.Incomplete {
+		t.Errorf("Expected no partial plan with the backward search, got %v", err)
+	}
+}
+
+func TestFindPlan_FallbackCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result, err := FindPlan(createFallbackAgent(), PlanOptions{MaxDepth: 2000, Fallback: true, Context: ctx})
+	if !errors.Is(err, ErrCanceled) {
+		t.Fatalf("Expected ErrCanceled, got %v", err)
+	}
+	if len(result.Skipped) != 0 {
+		t.Errorf("Expected the planning to stop at the first goal, got %d skipped goals", len(result.Skipped))
+	}
+}
+
+func createFallbackAgent() Agent {
+	actions := Actions{}
+	actions.AddAction("increment", 1.0, true, Conditions{}, Effects{
+		Effect[int]{Key: 1, Value: 1, Operator: ADD},
+	})
+	actions.AddAction("get_wood", 1.0, false, Conditions{}, Effects{
+		EffectBool{Key: 2, Value: true, Operator: SET},
+	})
+
+	goals := Goals{
+		"unreachable": {
+			Conditions: Conditions{
+				&ConditionBool{Key: 3, Value: true, Operator: EQUAL},
+			},
+			PriorityFn: func(sensors Sensors) float32 {
+				return 3.0
+			},
+		},
+		"reach_1000": {
+			Conditions: Conditions{
+				&Condition[int]{Key: 1, Value: 1000, Operator: EQUAL},
+			},
+			PriorityFn: func(sensors Sensors) float32 {
+				return 2.0
+			},
+		},
+		"get_wood": {
+			Conditions: Conditions{
+				&ConditionBool{Key: 2, Value: true, Operator: EQUAL},
+			},
+			PriorityFn: func(sensors Sensors) float32 {
+				return 1.0
+			},
+		},
+		"inactive": {
+			Conditions: Conditions{},
+			PriorityFn: func(sensors Sensors) float32 {
+				return 0.0
+			},
+		},
+	}
+
+	agent := CreateAgent(goals, actions)
+	SetState[int](&agent, 1, 0)
+	SetState[bool](&agent, 2, false)
+	SetState[bool](&agent, 3, false)
+
+	return agent
+}
+
+func TestFindPlan_Fallback(t *testing.T) {
+	agent := createFallbackAgent()
+
+	// The backward search finds quickly that no action leads to the 'unreachable' goal
+	result, err := FindPlan(agent, PlanOptions{MaxDepth: 2000, Direction: BACKWARD, Fallback: true, GoalMaxNodes: 50})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if result.GoalName != "get_wood" {
+		t.Errorf("Expected goal 'get_wood', got '%s'", result.GoalName)
+	}
+	if len(result.Plan) != 2 {
+		t.Errorf("Expected plan with 2 actions (root + 1), got %d", len(result.Plan))
+	}
+
+	if len(result.Skipped) != 2 {
+		t.Fatalf("Expected 2 skipped goals, got %d", len(result.Skipped))
+	}
+	if result.Skipped[0].GoalName != "unreachable" || !errors.Is(result.Skipped[0].Err, ErrGoalUnreachable) {
+		t.Errorf("Expected 'unreachable' to be skipped as unreachable, got %+v", result.Skipped[0])
+	}
+	if result.Skipped[1].GoalName != "reach_1000" || !errors.Is(result.Skipped[1].Err, ErrNodeBudget) {
+		t.Errorf("Expected 'reach_1000' to be skipped by the node budget, got %+v", result.Skipped[1])
+	}
+	if result.Skipped[1].Priority != 2.0 {
+		t.Errorf("Expected skipped priority 2.0, got %f", result.Skipped[1].Priority)
+	}
+	if result.NodesExpanded < 50 {
+		t.Errorf("Expected statistics to include all goals, got %d expanded nodes", result.NodesExpanded)
+	}
+}
+
+func TestFindPlan_FallbackGoalTimeout(t *testing.T) {
+	agent := createFallbackAgent()
+
+	result, err := FindPlan(agent, PlanOptions{MaxDepth: 2000, Fallback: true, GoalTimeout: time.Nanosecond})
+	if !errors.Is(err, ErrGoalUnreachable) {
+		t.Fatalf("Expected ErrGoalUnreachable, got %v", err)
+	}
+
+	if len(result.Skipped) != 3 {
+		t.Fatalf("Expected 3 skipped goals, got %d", len(result.Skipped))
+	}
+	if !errors.Is(result.Skipped[1].Err, ErrDeadline) {
+		t.Errorf("Expected 'reach_1000' to be skipped by the deadline, got %v", result.Skipped[1].Err)
+	}
+}
+
+func TestFindPlan_FallbackNoGoal(t *testing.T) {
+	agent := CreateAgent(Goals{}, Actions{})
+
+	if _, err := FindPlan(agent, PlanOptions{MaxDepth: 10, Fallback: true}); !errors.Is(err, ErrNoGoalAvailable) {
+		t.Errorf("Expected ErrNoGoalAvailable, got %v", err)
+	}
+}
+
+func TestGetSortedGoals(t *testing.T) {
+	agent := createFallbackAgent()
+
+	goals := agent.getSortedGoals()
+	want := []GoalName{"unreachable", "reach_1000", "get_wood"}
+
+	if len(goals) != len(want) {
+		t.Fatalf("Expected %d goals, got %d", len(want), len(goals))
+	}
+	for i, goal := range goals {
+		if goal.name != want[i] {
+			t.Errorf("Expected goal %d to be '%s', got '%s'", i, want[i], goal.name)
+		}
+	}
+}
+
+func createUtilityAgent() Agent {
+	actions := Actions{}
+	actions.AddAction("attack", 10.0, false, Conditions{}, Effects{
+		EffectBool{Key: 1, Value: true, Operator: SET},
+	})
+	actions.AddAction("eat", 1.0, false, Conditions{}, Effects{
+		EffectBool{Key: 2, Value: true, Operator: SET},
+	})
+
+	goals := Goals{
+		"kill": {
+			Conditions: Conditions{
+				&ConditionBool{Key: 1, Value: true, Operator: EQUAL},
+			},
+			PriorityFn: func(sensors Sensors) float32 {
+				return 1.1
+			},
+		},
+		"feed": {
+			Conditions: Conditions{
+				&ConditionBool{Key: 2, Value: true, Operator: EQUAL},
+			},
+			PriorityFn: func(sensors Sensors) float32 {
+				return 1.0
+			},
+		},
+		"fly": {
+			Conditions: Conditions{
+				&ConditionBool{Key: 3, Value: true, Operator: EQUAL},
+			},
+			PriorityFn: func(sensors Sensors) float32 {
+				return 0.5
+			},
+		},
+	}
+
+	agent := CreateAgent(goals, actions)
+	SetState[bool](&agent, 1, false)
+	SetState[bool](&agent, 2, false)
+	SetState[bool](&agent, 3, false)
+
+	return agent
+}
+
+func TestFindPlan_Utility(t *testing.T) {
+	tests := []struct {
+		name        string
+		options     PlanOptions
+		wantGoal    GoalName
+		wantSkipped int
+	}{
+		{"priority only", PlanOptions{MaxDepth: 10}, "kill", 0},
+		{"priority per cost", PlanOptions{MaxDepth: 10, UtilityFn: UtilityPriorityPerCost}, "feed", 1},
+		{"single candidate", PlanOptions{MaxDepth: 10, UtilityFn: UtilityPriorityPerCost, UtilityCandidates: 1}, "kill", 0},
+		{"custom utility", PlanOptions{MaxDepth: 10, UtilityFn: func(priority float32, cost float32, length int) float32 {
+			return priority
+		}}, "kill", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := FindPlan(createUtilityAgent(), tt.options)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if result.GoalName != tt.wantGoal {
+				t.Errorf("Expected goal '%s', got '%s'", tt.wantGoal, result.GoalName)
+			}
+			if len(result.Skipped) != tt.wantSkipped {
+				t.Errorf("Expected %d skipped goals, got %d", tt.wantSkipped, len(result.Skipped))
+			}
+		})
+	}
+}
+
+func TestFindPlan_UtilityNoAchievableGoal(t *testing.T) {
+	agent := createUtilityAgent()
+
+	result, err := FindPlan(agent, PlanOptions{MaxDepth: 10, UtilityFn: UtilityPriorityPerCost, UtilityCandidates: 1, GoalMaxNodes: 1})
+	if !errors.Is(err, ErrGoalUnreachable) {
+		t.Errorf("Expected ErrGoalUnreachable, got %v", err)
+	}
+	if len(result.Skipped) != 1 || !errors.Is(result.Skipped[0].Err, ErrNodeBudget) {
+		t.Errorf("Expected the goal to be skipped by the node budget, got %+v", result.Skipped)
+	}
+}
@@ -0,0 +1,108 @@
+package goapai
+
+import "sync"
+
+// PlanResponse is the outcome of a request submitted to a PlannerPool.
+type PlanResponse struct {
+	Result PlanResult
+	Err    error
+}
+
+// PlannerPool plans the requests of many agents concurrently, on a bounded number of workers.
+//
+// Submitting a request takes a copy of the agent's world state and computes the priorities of its goals
+// on the calling goroutine, so the agent can be updated right after. The sensors are read by the
+// ConditionFn during planning though: they must not be modified until the response is delivered.
+// The Actions and Goals definitions are never modified by the planner, and can be shared by all the agents.
+//
+// Example:
+//
+//	pool := goapai.CreatePlannerPool(4)
+//	defer pool.Close()
+//
+//	responses := make([]<-chan goapai.PlanResponse, len(entities))
+//	for i, entity := range entities {
+//	    responses[i] = pool.Submit(entity.agent, goapai.PlanOptions{MaxDepth: 10})
+//	}
+//	for i, response := range responses {
+//	    entities[i].plan = (<-response).Result.Plan
+//	}
+type PlannerPool struct {
+	requests chan poolRequest
+	workers  sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+type poolRequest struct {
+	planner  *Planner
+	callback func(result PlanResult, err error)
+}
+
+// CreatePlannerPool creates a PlannerPool and starts its workers.
+// The number of workers is at least 1.
+func CreatePlannerPool(workers int) *PlannerPool {
+	workers = max(workers, 1)
+	pool := &PlannerPool{
+		requests: make(chan poolRequest, workers),
+	}
+
+	pool.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go pool.work()
+	}
+
+	return pool
+}
+
+func (pool *PlannerPool) work() {
+	defer pool.workers.Done()
+
+	for request := range pool.requests {
+		request.callback(request.planner.run())
+	}
+}
+
+// Submit queues a planning request for the agent, and returns the channel receiving its response.
+// It blocks while all the workers are busy and the queue is full.
+// Once the pool is closed, the response holds ErrPoolClosed.
+func (pool *PlannerPool) Submit(agent Agent, options PlanOptions) <-chan PlanResponse {
+	response := make(chan PlanResponse, 1)
+
+	pool.SubmitFunc(agent, options, func(result PlanResult, err error) {
+		response <- PlanResponse{Result: result, Err: err}
+	})
+
+	return response
+}
+
+// SubmitFunc queues a planning request for the agent, and calls callback with its outcome.
+// The callback is called from a worker goroutine, and delays the next requests until it returns.
+// It blocks while all the workers are busy and the queue is full.
+// Once the pool is closed, the callback is called immediately with ErrPoolClosed.
+func (pool *PlannerPool) SubmitFunc(agent Agent, options PlanOptions, callback func(result PlanResult, err error)) {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	if pool.closed {
+		callback(PlanResult{Plan: Plan{}}, ErrPoolClosed)
+		return
+	}
+
+	pool.requests <- poolRequest{planner: CreatePlanner(agent, options), callback: callback}
+}
+
+// Close stops accepting new requests, and waits for the queued requests to be planned.
+func (pool *PlannerPool) Close() {
+	pool.mu.Lock()
+	if pool.closed {
+		pool.mu.Unlock()
+		return
+	}
+	pool.closed = true
+	close(pool.requests)
+	pool.mu.Unlock()
+
+	pool.workers.Wait()
+}
@@ -0,0 +1,124 @@
+package goapai
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// createSharedDomain creates actions and goals shared by several agents.
+// Whether an agent can chop wood depends on its "has_axe" sensor.
+func createSharedDomain() (Goals, Actions) {
+	actions := Actions{}
+	actions.AddAction("chop_wood", 1.0, false, Conditions{
+		&ConditionFn{
+			Key: 10,
+			CheckFn: func(sensors Sensors) bool {
+				return sensors.GetSensor("has_axe").(bool)
+			},
+		},
+	}, Effects{
+		EffectBool{Key: 1, Value: true, Operator: SET},
+	})
+	actions.AddAction("buy_wood", 5.0, false, Conditions{}, Effects{
+		EffectBool{Key: 1, Value: true, Operator: SET},
+	})
+
+	goals := Goals{
+		"get_wood": {
+			Conditions: Conditions{
+				&ConditionBool{Key: 1, Value: true, Operator: EQUAL},
+			},
+			PriorityFn: func(sensors Sensors) float32 {
+				return 1.0
+			},
+		},
+	}
+
+	return goals, actions
+}
+
+func TestPlannerPool_Submit(t *testing.T) {
+	goals, actions := createSharedDomain()
+	pool := CreatePlannerPool(4)
+	defer pool.Close()
+
+	agents := make([]Agent, 50)
+	responses := make([]<-chan PlanResponse, len(agents))
+	for i := range agents {
+		agents[i] = CreateAgent(goals, actions)
+		SetState[bool](&agents[i], 1, false)
+		SetSensor(&agents[i], "has_axe", i%2 == 0)
+
+		responses[i] = pool.Submit(agents[i], PlanOptions{MaxDepth: 5})
+	}
+
+	for i, response := range responses {
+		r := <-response
+		if r.Err != nil {
+			t.Fatalf("Agent %d: unexpected error: %v", i, r.Err)
+		}
+		if len(r.Result.Plan) != 2 {
+			t.Fatalf("Agent %d: expected plan with 2 actions, got %d", i, len(r.Result.Plan))
+		}
+
+		// The ConditionFn is evaluated with the sensors of each agent
+		expected := "buy_wood"
+		if i%2 == 0 {
+			expected = "chop_wood"
+		}
+		if r.Result.Plan[1].name != expected {
+			t.Errorf("Agent %d: expected action '%s', got '%s'", i, expected, r.Result.Plan[1].name)
+		}
+	}
+}
+
+func TestPlannerPool_SubmitFunc(t *testing.T) {
+	goals, actions := createSharedDomain()
+	pool := CreatePlannerPool(2)
+
+	var mu sync.Mutex
+	results := map[int]string{}
+	var wg sync.WaitGroup
+
+	// Requests submitted from several goroutines
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			agent := CreateAgent(goals, actions)
+			SetState[bool](&agent, 1, false)
+			SetSensor(&agent, "has_axe", i%2 == 0)
+
+			pool.SubmitFunc(agent, PlanOptions{MaxDepth: 5, Direction: direction(i % 2)}, func(result PlanResult, err error) {
+				mu.Lock()
+				defer mu.Unlock()
+				results[i] = fmt.Sprint(len(result.Plan), err)
+			})
+		}(i)
+	}
+	wg.Wait()
+	pool.Close()
+
+	if len(results) != 20 {
+		t.Fatalf("Expected 20 results after Close, got %d", len(results))
+	}
+	for i, result := range results {
+		if result != "2 <nil>" {
+			t.Errorf("Request %d: expected plan with 2 actions, got %s", i, result)
+		}
+	}
+}
+
+func TestPlannerPool_Closed(t *testing.T) {
+	pool := CreatePlannerPool(0)
+	pool.Close()
+	pool.Close()
+
+	response := <-pool.Submit(CreateAgent(Goals{}, Actions{}), PlanOptions{MaxDepth: 5})
+	if !errors.Is(response.Err, ErrPoolClosed) {
+		t.Errorf("Expected ErrPoolClosed, got %v", response.Err)
+	}
+}
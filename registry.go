@@ -0,0 +1,262 @@
+package goapai
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+)
+
+// RegisterNamedState declares the state key with the type T and a human-readable name in the schema.
+//
+// The name can be used to look up the key with GetKey, and is printed instead of the key by the
+// error messages and the debugging output. It returns an error if the key is already registered with
+// another type or another name, if the name is already used by another key, or if the key is not
+// registered yet with this name and an agent already uses the schema.
+//
+// Example:
+//
+//	schema := CreateSchema()
+//	RegisterNamedState[int](schema, ATTRIBUTE_HEALTH, "health")
+//	RegisterNamedState[bool](schema, ATTRIBUTE_HUNGRY, "hungry")
+func RegisterNamedState[T Numeric | bool | string](schema *Schema, key StateKey, name string) error {
+	if name == "" {
+		return fmt.Errorf("%w: empty name for state %d", ErrSchemaViolation, key)
+	}
+	if slot, ok := schema.slotByName[name]; ok && schema.keys[slot] != key {
+		return fmt.Errorf("%w: name %q is already registered for state %d", ErrSchemaViolation, name, schema.keys[slot])
+	}
+
+	if registered, ok := schema.GetName(key); ok && registered != name {
+		return fmt.Errorf("%w: state %d is already registered as %q", ErrSchemaViolation, key, registered)
+	} else if ok {
+		return RegisterState[T](schema, key)
+	}
+	if schema.inUse.Load() {
+		return fmt.Errorf("%w: state %d registered after an agent was created with the schema", ErrSchemaViolation, key)
+	}
+
+	if err := RegisterState[T](schema, key); err != nil {
+		return err
+	}
+	slot, _ := schema.slot(key)
+
+	if schema.slotByName == nil {
+		schema.slotByName = map[string]int{}
+	}
+	schema.names[slot] = name
+	schema.slotByName[name] = slot
+
+	return nil
+}
+
+// GetKey returns the key of the state registered with name, and false if the name is not registered.
+func (schema *Schema) GetKey(name string) (StateKey, bool) {
+	if schema == nil {
+		return 0, false
+	}
+
+	slot, ok := schema.slotByName[name]
+	if !ok {
+		return 0, false
+	}
+
+	return schema.keys[slot], true
+}
+
+// GetName returns the name of the state key, and false if the key is not registered with a name.
+func (schema *Schema) GetName(key StateKey) (string, bool) {
+	slot, ok := schema.slot(key)
+	if !ok || schema.names[slot] == "" {
+		return "", false
+	}
+
+	return schema.names[slot], true
+}
+
+// FormatKey returns the name of the state key if it is registered with one, or the key as a number otherwise.
+// It can be called on a nil Schema.
+func (schema *Schema) FormatKey(key StateKey) string {
+	if name, ok := schema.GetName(key); ok {
+		return strconv.Quote(name)
+	}
+
+	return strconv.Itoa(int(key))
+}
+
+// Validate checks that the conditions and effects of the goals and actions use the states with
+// their registered type. The states that are not registered must be used with the same type
+// everywhere. The actions of composite actions, and the tasks of the goals, are checked as well.
+//
+// The ConditionFn, EffectFn and custom conditions and effects are not checked, nor the actions
+// bound from templates. All the violations are reported, joined in an error matching ErrSchemaViolation.
+// Validate can be called on a nil Schema, for which no state is registered.
+func (schema *Schema) Validate(goals Goals, actions Actions) error {
+	return schema.validate(goals, actions, nil)
+}
+
+// CreateValidatedAgent creates an agent like CreateAgentWithSchema, initialised with states, after checking
+// that the goals, the actions and the states use the types registered in the schema (see Schema.Validate).
+func CreateValidatedAgent(goals Goals, actions Actions, schema *Schema, states ...StateInterface) (Agent, error) {
+	if err := schema.validate(goals, actions, states); err != nil {
+		return Agent{}, err
+	}
+
+	agent := createAgentWithSchema(goals, actions, schema)
+	agent.SetStates(states...)
+
+	return agent, nil
+}
+
+// typedValue is implemented by the states, conditions and effects whose type is known statically.
+type typedValue interface {
+	valueKind() stateKind
+}
+
+func (state State[T]) valueKind() stateKind {
+	return kindOf[T]()
+}
+
+func (condition *Condition[T]) valueKind() stateKind {
+	return kindOf[T]()
+}
+
+func (conditionBool *ConditionBool) valueKind() stateKind {
+	return kindBool
+}
+
+func (conditionString *ConditionString) valueKind() stateKind {
+	return kindString
+}
+
+func (effect Effect[T]) valueKind() stateKind {
+	return kindOf[T]()
+}
+
+func (effectBool EffectBool) valueKind() stateKind {
+	return kindBool
+}
+
+func (effectString EffectString) valueKind() stateKind {
+	return kindString
+}
+
+// schemaValidator collects the violations of the schema, and the type of the states that are not registered.
+type schemaValidator struct {
+	schema  *Schema
+	usages  map[StateKey]schemaUsage
+	visited map[any]bool
+	errs    []error
+}
+
+// schemaUsage is the first use of a state that is not registered.
+type schemaUsage struct {
+	kind   stateKind
+	origin string
+}
+
+func (schema *Schema) validate(goals Goals, actions Actions, states []StateInterface) error {
+	validator := &schemaValidator{
+		schema:  schema,
+		usages:  map[StateKey]schemaUsage{},
+		visited: map[any]bool{},
+	}
+
+	for _, state := range states {
+		validator.check("state", state.GetKey(), state)
+	}
+
+	// Goals are sorted for the violations to be reported in a stable order
+	goalNames := make([]GoalName, 0, len(goals))
+	for name := range goals {
+		goalNames = append(goalNames, name)
+	}
+	slices.Sort(goalNames)
+
+	for _, name := range goalNames {
+		goal := goals[name]
+		validator.conditions(fmt.Sprintf("goal %q", name), goal.Conditions)
+		if goal.Task != nil {
+			validator.task(goal.Task)
+		}
+	}
+	validator.actions(actions)
+
+	return errors.Join(validator.errs...)
+}
+
+func (validator *schemaValidator) actions(actions Actions) {
+	for _, action := range actions {
+		if validator.visited[action] {
+			continue
+		}
+		validator.visited[action] = true
+
+		origin := fmt.Sprintf("action %q", action.name)
+		validator.conditions(origin, action.conditions)
+		for _, effect := range action.effects {
+			validator.check(origin+" effect", effect.GetKey(), effect)
+		}
+
+		if action.composite != nil {
+			validator.actions(action.composite.children)
+			validator.conditions(origin+" sub-goal", action.composite.subGoal)
+			validator.actions(action.composite.subActions)
+		}
+	}
+}
+
+func (validator *schemaValidator) task(task *Task) {
+	if validator.visited[task] {
+		return
+	}
+	validator.visited[task] = true
+
+	switch task.kind {
+	case primitiveTask:
+		validator.actions(Actions{task.action})
+	case goalTask:
+		validator.conditions(fmt.Sprintf("task %q", task.name), task.goal)
+		validator.actions(task.actions)
+	case compoundTask:
+		for _, method := range task.methods {
+			validator.conditions(fmt.Sprintf("task %q method %q", task.name, method.Name), method.Conditions)
+			for _, subtask := range method.Subtasks {
+				validator.task(subtask)
+			}
+		}
+	}
+}
+
+func (validator *schemaValidator) conditions(origin string, conditions Conditions) {
+	for _, condition := range conditions {
+		validator.check(origin+" condition", condition.GetKey(), condition)
+	}
+}
+
+// check records a violation if value is not of the type of the state key.
+func (validator *schemaValidator) check(origin string, key StateKey, value any) {
+	typed, ok := value.(typedValue)
+	if !ok {
+		return
+	}
+	kind := typed.valueKind()
+
+	if slot, ok := validator.schema.slot(key); ok {
+		if registered := validator.schema.kinds[slot]; kind != registered {
+			validator.errs = append(validator.errs, fmt.Errorf("%w: %s uses state %s as %s, registered as %s",
+				ErrSchemaViolation, origin, validator.schema.FormatKey(key), kind, registered))
+		}
+		return
+	}
+
+	usage, ok := validator.usages[key]
+	if !ok {
+		validator.usages[key] = schemaUsage{kind: kind, origin: origin}
+		return
+	}
+	if kind != usage.kind {
+		validator.errs = append(validator.errs, fmt.Errorf("%w: %s uses state %d as %s, %s uses it as %s",
+			ErrSchemaViolation, origin, key, kind, usage.origin, usage.kind))
+	}
+}
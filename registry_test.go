@@ -0,0 +1,169 @@
+package goapai
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func createNamedSchema(t *testing.T) *Schema {
+	schema := CreateSchema()
+	if err := RegisterNamedState[int](schema, 1, "wood"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := RegisterNamedState[bool](schema, 2, "has_axe"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	return schema
+}
+
+func TestRegisterNamedState(t *testing.T) {
+	schema := createNamedSchema(t)
+
+	if err := RegisterNamedState[int](schema, 1, "wood"); err != nil {
+		t.Errorf("Expected registering the same state twice to succeed, got %v", err)
+	}
+	if err := RegisterNamedState[int](schema, 3, "wood"); !errors.Is(err, ErrSchemaViolation) {
+		t.Errorf("Expected ErrSchemaViolation for a name already used, got %v", err)
+	}
+	if err := RegisterNamedState[int](schema, 1, "logs"); !errors.Is(err, ErrSchemaViolation) {
+		t.Errorf("Expected ErrSchemaViolation for a state already named, got %v", err)
+	}
+	if err := RegisterNamedState[string](schema, 2, "has_axe"); !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("Expected ErrTypeMismatch, got %v", err)
+	}
+	if err := RegisterNamedState[int](schema, 4, ""); !errors.Is(err, ErrSchemaViolation) {
+		t.Errorf("Expected ErrSchemaViolation for an empty name, got %v", err)
+	}
+	if _, ok := schema.slot(3); ok {
+		t.Error("Expected key 3 not to be registered")
+	}
+
+	if key, ok := schema.GetKey("has_axe"); !ok || key != 2 {
+		t.Errorf("Expected key 2, got %d, %v", key, ok)
+	}
+	if _, ok := schema.GetKey("stone"); ok {
+		t.Error("Expected stone not to be registered")
+	}
+
+	// The states registered without name are formatted as their key
+	if err := RegisterState[int](schema, 5); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := schema.GetName(5); ok {
+		t.Error("Expected state 5 to have no name")
+	}
+
+	tests := []struct {
+		schema   *Schema
+		key      StateKey
+		expected string
+	}{
+		{schema, 1, `"wood"`},
+		{schema, 5, "5"},
+		{schema, 9, "9"},
+		{nil, 1, "1"},
+	}
+	for _, test := range tests {
+		if result := test.schema.FormatKey(test.key); result != test.expected {
+			t.Errorf("Expected %s, got %s", test.expected, result)
+		}
+	}
+}
+
+func TestRegisterNamedState_SchemaInUse(t *testing.T) {
+	schema := createNamedSchema(t)
+	if err := RegisterState[int](schema, 3); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := CreateAgentWithSchema(Goals{}, Actions{}, schema); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if err := RegisterNamedState[int](schema, 1, "wood"); err != nil {
+		t.Errorf("Expected registering a named state again to succeed, got %v", err)
+	}
+	if err := RegisterNamedState[int](schema, 3, "health"); !errors.Is(err, ErrSchemaViolation) {
+		t.Errorf("Expected ErrSchemaViolation for naming a registered state, got %v", err)
+	}
+	if _, ok := schema.GetKey("health"); ok {
+		t.Error("Expected the name not to be registered")
+	}
+}
+
+func TestSchemaValidate(t *testing.T) {
+	schema := createNamedSchema(t)
+
+	sit := &Action{name: "sit", effects: Effects{EffectBool{Key: 10, Value: true, Operator: SET}}}
+	actions := Actions{}
+	actions.AddAction("chop_wood", 1, true, Conditions{
+		&ConditionBool{Key: 2, Value: true, Operator: EQUAL},
+	}, Effects{
+		Effect[int]{Key: 1, Value: 1, Operator: ADD},
+		Effect[int]{Key: 10, Value: 1, Operator: SET},
+	})
+	actions.AddCompositeAction("gather", 1, false, Conditions{}, Effects{}, Actions{
+		{name: "carry", effects: Effects{Effect[float64]{Key: 1, Value: 1, Operator: ADD}}},
+	})
+
+	goals := Goals{
+		"gather_wood": {
+			Conditions: Conditions{&Condition[int]{Key: 1, Value: 5, Operator: UPPER_OR_EQUAL}},
+			Task: CreateCompoundTask("stay_warm", Method{
+				Name:       "sit",
+				Conditions: Conditions{&ConditionString{Key: 2, Value: "yes", Operator: EQUAL}},
+				Subtasks:   []*Task{CreatePrimitiveTask(sit)},
+			}),
+		},
+	}
+
+	err := schema.Validate(goals, actions)
+	if !errors.Is(err, ErrSchemaViolation) {
+		t.Fatalf("Expected ErrSchemaViolation, got %v", err)
+	}
+
+	expected := []string{
+		`task "stay_warm" method "sit" condition uses state "has_axe" as string, registered as bool`,
+		`action "chop_wood" effect uses state 10 as int, action "sit" effect uses it as bool`,
+		`action "carry" effect uses state "wood" as float64, registered as int`,
+	}
+	violations := strings.Split(err.Error(), "\n")
+	if len(violations) != len(expected) {
+		t.Fatalf("Expected %d violations, got %d: %v", len(expected), len(violations), err)
+	}
+	for i, violation := range violations {
+		if !strings.HasSuffix(violation, expected[i]) {
+			t.Errorf("Expected violation %q, got %q", expected[i], violation)
+		}
+	}
+
+	if err := schema.Validate(Goals{"gather_wood": {Conditions: goals["gather_wood"].Conditions}}, actions[:1]); err != nil {
+		t.Errorf("Expected no violation, got %v", err)
+	}
+}
+
+func TestCreateValidatedAgent(t *testing.T) {
+	schema := createNamedSchema(t)
+
+	actions := Actions{}
+	actions.AddAction("chop_wood", 1, true, Conditions{}, Effects{Effect[int]{Key: 1, Value: 1, Operator: ADD}})
+
+	if _, err := CreateValidatedAgent(Goals{}, actions, schema, State[int]{Key: 2, Value: 1}); !errors.Is(err, ErrSchemaViolation) {
+		t.Errorf("Expected ErrSchemaViolation, got %v", err)
+	}
+
+	agent, err := CreateValidatedAgent(Goals{}, actions, schema, State[int]{Key: 1, Value: 3}, State[bool]{Key: 2, Value: true})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if wood, ok := GetState[int](&agent, 1); !ok || wood != 3 {
+		t.Errorf("Expected wood 3, got %d, %v", wood, ok)
+	}
+
+	// The type mismatches found during planning print the name of the state
+	err = EffectBool{Key: 1, Value: true, Operator: SET}.apply(&agent.w)
+	if !errors.Is(err, ErrTypeMismatch) || !strings.Contains(err.Error(), `state "wood"`) {
+		t.Errorf("Expected ErrTypeMismatch on state \"wood\", got %v", err)
+	}
+}
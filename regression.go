@@ -0,0 +1,520 @@
+package goapai
+
+import (
+	"cmp"
+	"container/heap"
+	"errors"
+	"fmt"
+	"hash/maphash"
+	"slices"
+)
+
+// regressiveCondition is implemented by the conditions supported by the backward search.
+//
+// regress returns the condition that must hold before the effect is applied, so that
+// the condition holds after it. A nil condition means the effect satisfies the condition
+// by itself. The boolean is false if the effect makes the condition impossible to
+// satisfy, or if the regression of this effect is not supported.
+type regressiveCondition interface {
+	regress(effect EffectInterface) (ConditionInterface, bool)
+}
+
+func (condition *Condition[T]) regress(effect EffectInterface) (ConditionInterface, bool) {
+	e, ok := effect.(Effect[T])
+	if !ok {
+		return nil, false
+	}
+
+	switch e.Operator {
+	case SET:
+		return nil, condition.compare(e.Value)
+	case ADD:
+		value := condition.Value - e.Value
+		if (e.Value > 0 && value > condition.Value) || (e.Value < 0 && value < condition.Value) {
+			// The regressed value wrapped around, e.g. below zero for an unsigned type
+			return regressOutOfRange(condition.Operator, e.Value > 0)
+		}
+		// The effect creates the state if it is missing, from its zero value
+		return &Condition[T]{Key: condition.Key, Value: value, Operator: condition.Operator, orZero: true}, true
+	case SUBSTRACT:
+		value := condition.Value + e.Value
+		if (e.Value > 0 && value < condition.Value) || (e.Value < 0 && value > condition.Value) {
+			return regressOutOfRange(condition.Operator, e.Value < 0)
+		}
+		return &Condition[T]{Key: condition.Key, Value: value, Operator: condition.Operator, orZero: true}, true
+	}
+
+	// MULTIPLY and DIVIDE are not reversible with integers
+	return nil, false
+}
+
+// regressOutOfRange regresses a condition whose regressed value is out of the range of its type: below
+// its lowest value if below is true, above its highest value otherwise. No state before the effect can
+// then be equal to the regressed value, so that the condition either always holds after the effect, or never.
+func regressOutOfRange(op operator, below bool) (ConditionInterface, bool) {
+	switch op {
+	case UPPER_OR_EQUAL, UPPER:
+		return nil, below
+	case LOWER_OR_EQUAL, LOWER:
+		return nil, !below
+	case NOT_EQUAL:
+		return nil, true
+	}
+
+	return nil, false
+}
+
+func (conditionBool *ConditionBool) regress(effect EffectInterface) (ConditionInterface, bool) {
+	e, ok := effect.(EffectBool)
+	if !ok || e.Operator != SET {
+		return nil, false
+	}
+
+	return nil, conditionBool.compare(e.Value)
+}
+
+func (conditionString *ConditionString) regress(effect EffectInterface) (ConditionInterface, bool) {
+	e, ok := effect.(EffectString)
+	if !ok || e.Operator != SET {
+		return nil, false
+	}
+
+	return nil, conditionString.compare(e.Value)
+}
+
+// ConditionFn only depends on the sensors, that are not modified by effects.
+// The backward search rejects the ConditionFn with a WorldFn, see createBackwardSearch.
+func (conditionFn *ConditionFn) regress(effect EffectInterface) (ConditionInterface, bool) {
+	return conditionFn, true
+}
+
+// hasWorldFn returns true if one of the conditions is a ConditionFn with a WorldFn.
+func hasWorldFn(conditions Conditions) bool {
+	return slices.ContainsFunc(conditions, func(condition ConditionInterface) bool {
+		conditionFn, ok := condition.(*ConditionFn)
+		return ok && conditionFn.WorldFn != nil
+	})
+}
+
+// mergeableCondition is implemented by the conditions compared by value by the backward search, so that
+// equivalent sets of regressed conditions lead to the same node.
+type mergeableCondition interface {
+	ConditionInterface
+	// operands returns the operator and the value compared with the state.
+	operands() (operator, any)
+	// merge returns the condition holding when both the condition and other hold. The boolean is false
+	// if other is not a condition of the same type, state and operator, or if they cannot be merged.
+	merge(other ConditionInterface) (ConditionInterface, bool)
+}
+
+func (condition *Condition[T]) operands() (operator, any) {
+	return condition.Operator, condition.Value
+}
+
+func (condition *Condition[T]) merge(other ConditionInterface) (ConditionInterface, bool) {
+	o, ok := other.(*Condition[T])
+	if !ok || o.Key != condition.Key || o.Operator != condition.Operator {
+		return nil, false
+	}
+	if o.orZero != condition.orZero {
+		// The merged condition requires the state to exist if one of them does
+		strict, strictOther := *condition, *o
+		strict.orZero, strictOther.orZero = false, false
+		return strict.merge(&strictOther)
+	}
+
+	switch condition.Operator {
+	case UPPER_OR_EQUAL, UPPER:
+		if o.Value > condition.Value {
+			return o, true
+		}
+		return condition, true
+	case LOWER_OR_EQUAL, LOWER:
+		if o.Value < condition.Value {
+			return o, true
+		}
+		return condition, true
+	}
+
+	return condition, o.Value == condition.Value
+}
+
+func (conditionBool *ConditionBool) operands() (operator, any) {
+	return conditionBool.Operator, conditionBool.Value
+}
+
+func (conditionBool *ConditionBool) merge(other ConditionInterface) (ConditionInterface, bool) {
+	o, ok := other.(*ConditionBool)
+
+	return conditionBool, ok && *o == *conditionBool
+}
+
+func (conditionString *ConditionString) operands() (operator, any) {
+	return conditionString.Operator, conditionString.Value
+}
+
+func (conditionString *ConditionString) merge(other ConditionInterface) (ConditionInterface, bool) {
+	o, ok := other.(*ConditionString)
+
+	return conditionString, ok && *o == *conditionString
+}
+
+// regressiveSearch runs a backward A* search: it starts from the goal's conditions, and
+// regresses them through the actions whose effects satisfy at least one of them, until the
+// remaining conditions hold in the world from.
+//
+// Only the actions relevant to the goal are expanded, at the cost of a few restrictions:
+// MULTIPLY and DIVIDE effects, and custom conditions, cannot be regressed. Actions using them
+// on a key required by the remaining conditions are skipped. Each plan found is replayed forward
+// before being returned, so that it is guaranteed to be valid.
+func regressiveSearch(from world, goal goalInterface, actions Actions, options PlanOptions) (Plan, SearchStats, error) {
+	search := createBackwardSearch(from, goal, actions, options)
+	for !search.expand() {
+	}
+
+	return search.result()
+}
+
+// backwardSearch holds the state of a backward A* search between two expansions.
+type backwardSearch struct {
+	from             world
+	goal             goalInterface
+	availableActions Actions
+	options          PlanOptions
+
+	nodesHeap nodeHeap
+	nodes     conditionIndex // Open and closed nodes indexed by their conditions
+
+	stats        SearchStats
+	depthReached bool
+
+	done bool
+	plan Plan
+	err  error
+}
+
+func createBackwardSearch(from world, goal goalInterface, actions Actions, options PlanOptions) *backwardSearch {
+	search := &backwardSearch{
+		from:             from,
+		goal:             goal,
+		availableActions: getImpactingActions(from, actions),
+		options:          options,
+		nodesHeap:        nodeHeap{},
+		nodes:            conditionIndex{},
+	}
+	if actions.hasTemplates() {
+		search.availableActions = bindActions(from, search.availableActions, nil)
+	}
+
+	// A WorldFn depends on the simulated world states, that a regression does not compute
+	if hasWorldFn(goal.Conditions) || slices.ContainsFunc(search.availableActions, func(action *Action) bool { return hasWorldFn(action.conditions) }) {
+		search.finish(Plan{}, fmt.Errorf("%w: ConditionFn with a WorldFn in a BACKWARD search", errors.ErrUnsupported))
+		return search
+	}
+
+	if countMissingGoal(goal, from) == 0 {
+		search.finish(Plan{&Action{start: true}}, nil)
+		return search
+	}
+
+	goalNode := &node{
+		Action:     &Action{start: true},
+		conditions: canonicalConditions(goal.Conditions),
+		heapIndex:  -1,
+	}
+
+	heap.Init(&search.nodesHeap)
+	heap.Push(&search.nodesHeap, goalNode)
+	search.nodes.add(goalNode)
+	search.stats.MaxOpenSet = 1
+
+	return search
+}
+
+func (search *backwardSearch) expand() bool {
+	if search.done {
+		return true
+	}
+
+	if search.nodesHeap.Len() == 0 {
+		if search.depthReached {
+			return search.finish(Plan{}, ErrMaxDepth)
+		}
+		return search.finish(Plan{}, ErrGoalUnreachable)
+	}
+
+	if err := search.options.checkBudget(search.stats); err != nil {
+		return search.finish(Plan{}, err)
+	}
+
+	parentNode := heap.Pop(&search.nodesHeap).(*node)
+
+	if parentNode.depth > 0 && parentNode.conditions.Check(search.from) {
+		parentNode.closed = true
+		plan := buildPlanFromRegressionNode(parentNode)
+		if w, err := plan.replay(search.from.clone(), nil); err == nil && countMissingGoal(search.goal, w) == 0 {
+			return search.finish(recordStepCosts(search.from, plan), nil)
+		}
+		return false
+	}
+
+	// A node at the depth limit is not expanded, so it stays open to a shallower path
+	if parentNode.depth >= uint16(search.options.MaxDepth) {
+		search.depthReached = true
+		return false
+	}
+	parentNode.closed = true
+	search.stats.NodesExpanded++
+
+	for _, action := range search.availableActions {
+		if !allowedRepetition(action, parentNode) {
+			continue
+		}
+
+		conditions, ok := regressConditions(search.from, parentNode.conditions, action)
+		if !ok {
+			continue
+		}
+		conditions = canonicalConditions(conditions)
+
+		cost := action.getCost(search.from)
+		if currentNode, found := search.nodes.fetch(conditions); found {
+			// Each set of conditions is expanded at most once, closed nodes are never reopened.
+			// A node at the depth limit takes a shallower path, even if more expensive.
+			shallower := currentNode.depth >= uint16(search.options.MaxDepth) && parentNode.depth+1 < uint16(search.options.MaxDepth)
+			if !currentNode.closed && ((parentNode.cost+cost) < currentNode.cost || shallower) {
+				currentNode.Action = action
+				currentNode.parentNode = parentNode
+				currentNode.cost = parentNode.cost + cost
+				currentNode.totalCost = parentNode.cost + cost + currentNode.heuristic
+				currentNode.depth = parentNode.depth + 1
+
+				// Push back the node rejected by the depth limit
+				if currentNode.heapIndex < 0 {
+					heap.Push(&search.nodesHeap, currentNode)
+				} else {
+					heap.Fix(&search.nodesHeap, currentNode.heapIndex)
+				}
+			}
+			continue
+		}
+
+		heuristic := computeHeuristic(search.from, goalInterface{Conditions: conditions}, search.from)
+		newNode := &node{
+			Action:     action,
+			conditions: conditions,
+			parentNode: parentNode,
+			cost:       parentNode.cost + cost,
+			totalCost:  parentNode.cost + cost + heuristic,
+			heuristic:  heuristic,
+			depth:      parentNode.depth + 1,
+			heapIndex:  -1,
+		}
+		heap.Push(&search.nodesHeap, newNode)
+		search.nodes.add(newNode)
+		search.stats.NodesGenerated++
+		search.stats.MaxOpenSet = max(search.stats.MaxOpenSet, search.nodesHeap.Len())
+	}
+
+	return false
+}
+
+// finish ends the search with its outcome, and releases the nodes.
+func (search *backwardSearch) finish(plan Plan, err error) bool {
+	search.done = true
+	search.plan = plan
+	search.err = err
+	search.nodesHeap = nil
+	search.nodes = nil
+
+	return true
+}
+
+func (search *backwardSearch) result() (Plan, SearchStats, error) {
+	return search.plan, search.stats, search.err
+}
+
+func (search *backwardSearch) getStats() SearchStats {
+	return search.stats
+}
+
+// regressConditions returns the conditions that must hold before the action, so that
+// conditions hold after it. The boolean is false if the action breaks one of the conditions,
+// or if it does not get any condition closer to the world from, nor create one of its missing states.
+func regressConditions(from world, conditions Conditions, action *Action) (Conditions, bool) {
+	regressed := make(Conditions, 0, len(conditions)+len(action.conditions))
+	relevant := false
+
+	for _, condition := range conditions {
+		current, ok := regressCondition(condition, action)
+		if !ok {
+			return nil, false
+		}
+
+		if current == nil {
+			relevant = true
+			continue
+		}
+		// An effect on a missing state creates it: the distance to a missing state is only estimated
+		if current != condition && (conditionDistance(current, from) < conditionDistance(condition, from) || !from.hasState(condition.GetKey())) {
+			relevant = true
+		}
+
+		regressed = append(regressed, current)
+	}
+
+	if !relevant {
+		return nil, false
+	}
+
+	return append(regressed, action.conditions...), true
+}
+
+// regressCondition returns the condition that must hold before the action, so that condition
+// holds after it, or nil if the action satisfies it by itself. The boolean is false if the action
+// breaks the condition, or if the regression of one of its effects is not supported.
+func regressCondition(condition ConditionInterface, action *Action) (ConditionInterface, bool) {
+	current := condition
+
+	// Effects are applied in order, so they are regressed from the last one
+	for i := len(action.effects) - 1; i >= 0 && current != nil; i-- {
+		effect := action.effects[i]
+		if effect.GetKey() != current.GetKey() {
+			continue
+		}
+
+		regressive, ok := current.(regressiveCondition)
+		if !ok {
+			return nil, false
+		}
+		current, ok = regressive.regress(effect)
+		if !ok {
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+// recordStepCosts replaces the actions of a valid plan having a cost function by copies holding
+// the cost of their step, evaluated against the world replayed from w.
+func recordStepCosts(w world, plan Plan) Plan {
+	w = w.clone()
+
+	for i, action := range plan {
+		if action.costFn != nil {
+			plan[i] = action.withCost(action.getCost(w))
+		}
+		_ = action.effects.apply(&w)
+	}
+
+	return plan
+}
+
+// buildPlanFromRegressionNode returns the plan from a backward search node:
+// the node's action comes first, and its parents up to the goal node follow.
+func buildPlanFromRegressionNode(n *node) Plan {
+	plan := make(Plan, 0, n.depth+1)
+	plan = append(plan, &Action{start: true})
+
+	for ; n.parentNode != nil; n = n.parentNode {
+		plan = append(plan, n.Action)
+	}
+
+	return plan
+}
+
+// canonicalConditions merges the conditions on the same state with the same operator, removes the
+// duplicates and sorts them by state and operator, so that equivalent sets of conditions are equal.
+func canonicalConditions(conditions Conditions) Conditions {
+	canonical := make(Conditions, 0, len(conditions))
+
+	for _, condition := range conditions {
+		merged := false
+		if mergeable, ok := condition.(mergeableCondition); ok {
+			for i, other := range canonical {
+				if result, ok := mergeable.merge(other); ok {
+					canonical[i], merged = result, true
+					break
+				}
+			}
+		}
+		if !merged && !slices.ContainsFunc(canonical, func(other ConditionInterface) bool { return sameCondition(condition, other) }) {
+			canonical = append(canonical, condition)
+		}
+	}
+
+	slices.SortStableFunc(canonical, func(a, b ConditionInterface) int {
+		if c := cmp.Compare(a.GetKey(), b.GetKey()); c != 0 {
+			return c
+		}
+		return cmp.Compare(conditionOperator(a), conditionOperator(b))
+	})
+
+	return canonical
+}
+
+// sameCondition returns true if a and b are the same condition, or compare the same state with the same value.
+func sameCondition(a, b ConditionInterface) bool {
+	mergeableA, okA := a.(mergeableCondition)
+	mergeableB, okB := b.(mergeableCondition)
+	if !okA || !okB {
+		fnA, okA := a.(*ConditionFn)
+		fnB, okB := b.(*ConditionFn)
+		return okA && okB && fnA == fnB
+	}
+
+	operatorA, valueA := mergeableA.operands()
+	operatorB, valueB := mergeableB.operands()
+
+	return a.GetKey() == b.GetKey() && operatorA == operatorB && valueA == valueB
+}
+
+// conditionOperator returns the operator of a mergeable condition, or 0 for the other conditions.
+func conditionOperator(condition ConditionInterface) operator {
+	if mergeable, ok := condition.(mergeableCondition); ok {
+		op, _ := mergeable.operands()
+		return op
+	}
+
+	return 0
+}
+
+var conditionSeed = maphash.MakeSeed()
+
+// hashConditions returns the hash of canonical conditions.
+func hashConditions(conditions Conditions) uint64 {
+	var h maphash.Hash
+	h.SetSeed(conditionSeed)
+
+	for _, condition := range conditions {
+		maphash.WriteComparable(&h, condition.GetKey())
+		if mergeable, ok := condition.(mergeableCondition); ok {
+			op, value := mergeable.operands()
+			maphash.WriteComparable(&h, op)
+			maphash.WriteComparable(&h, value)
+		}
+	}
+
+	return h.Sum64()
+}
+
+// conditionIndex references the open and closed nodes of a backward search by their canonical conditions.
+// Several nodes share the same bucket in case of hash collision.
+type conditionIndex map[uint64][]*node
+
+func (index conditionIndex) add(n *node) {
+	hash := hashConditions(n.conditions)
+	index[hash] = append(index[hash], n)
+}
+
+// fetch returns the node with the same canonical conditions.
+func (index conditionIndex) fetch(conditions Conditions) (*node, bool) {
+	for _, n := range index[hashConditions(conditions)] {
+		if slices.EqualFunc(n.conditions, conditions, sameCondition) {
+			return n, true
+		}
+	}
+
+	return nil, false
+}
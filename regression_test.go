@@ -0,0 +1,487 @@
+package goapai
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestCondition_Regress(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition *Condition[int]
+		effect    EffectInterface
+		wantOk    bool
+		wantNil   bool
+		wantValue int
+	}{
+		{"SET satisfies", &Condition[int]{Key: 1, Value: 10, Operator: UPPER_OR_EQUAL}, Effect[int]{Key: 1, Value: 20, Operator: SET}, true, true, 0},
+		{"SET breaks", &Condition[int]{Key: 1, Value: 10, Operator: UPPER_OR_EQUAL}, Effect[int]{Key: 1, Value: 5, Operator: SET}, false, true, 0},
+		{"ADD", &Condition[int]{Key: 1, Value: 30, Operator: EQUAL}, Effect[int]{Key: 1, Value: 10, Operator: ADD}, true, false, 20},
+		{"SUBSTRACT", &Condition[int]{Key: 1, Value: 30, Operator: LOWER}, Effect[int]{Key: 1, Value: 10, Operator: SUBSTRACT}, true, false, 40},
+		{"MULTIPLY not supported", &Condition[int]{Key: 1, Value: 30, Operator: EQUAL}, Effect[int]{Key: 1, Value: 2, Operator: MULTIPLY}, false, true, 0},
+		{"type mismatch", &Condition[int]{Key: 1, Value: 30, Operator: EQUAL}, Effect[float64]{Key: 1, Value: 2, Operator: ADD}, false, true, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.condition.regress(tt.effect)
+			if ok != tt.wantOk {
+				t.Fatalf("regress() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if (got == nil) != tt.wantNil {
+				t.Fatalf("regress() condition = %v, want nil: %v", got, tt.wantNil)
+			}
+			if got != nil {
+				regressed := got.(*Condition[int])
+				if regressed.Value != tt.wantValue || regressed.Operator != tt.condition.Operator {
+					t.Errorf("regress() = %+v, want value %d", regressed, tt.wantValue)
+				}
+			}
+		})
+	}
+}
+
+func TestCondition_RegressUnsigned(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition ConditionInterface
+		effect    EffectInterface
+		wantOk    bool
+		wantNil   bool
+	}{
+		{"ADD saturates >=", &Condition[uint8]{Key: 1, Value: 5, Operator: UPPER_OR_EQUAL}, Effect[uint8]{Key: 1, Value: 10, Operator: ADD}, true, true},
+		{"ADD saturates >", &Condition[uint64]{Key: 1, Value: 5, Operator: UPPER}, Effect[uint64]{Key: 1, Value: 10, Operator: ADD}, true, true},
+		{"ADD breaks <=", &Condition[uint8]{Key: 1, Value: 5, Operator: LOWER_OR_EQUAL}, Effect[uint8]{Key: 1, Value: 10, Operator: ADD}, false, true},
+		{"ADD breaks ==", &Condition[uint8]{Key: 1, Value: 5, Operator: EQUAL}, Effect[uint8]{Key: 1, Value: 10, Operator: ADD}, false, true},
+		{"ADD within range", &Condition[uint8]{Key: 1, Value: 15, Operator: UPPER_OR_EQUAL}, Effect[uint8]{Key: 1, Value: 10, Operator: ADD}, true, false},
+		{"SUBSTRACT saturates <=", &Condition[uint8]{Key: 1, Value: 250, Operator: LOWER_OR_EQUAL}, Effect[uint8]{Key: 1, Value: 10, Operator: SUBSTRACT}, true, true},
+		{"SUBSTRACT breaks >=", &Condition[uint8]{Key: 1, Value: 250, Operator: UPPER_OR_EQUAL}, Effect[uint8]{Key: 1, Value: 10, Operator: SUBSTRACT}, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.condition.(regressiveCondition).regress(tt.effect)
+			if ok != tt.wantOk {
+				t.Fatalf("regress() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if (got == nil) != tt.wantNil {
+				t.Errorf("regress() condition = %v, want nil: %v", got, tt.wantNil)
+			}
+		})
+	}
+}
+
+func TestConditionBool_Regress(t *testing.T) {
+	condition := &ConditionBool{Key: 1, Value: true, Operator: EQUAL}
+
+	if got, ok := condition.regress(EffectBool{Key: 1, Value: true, Operator: SET}); !ok || got != nil {
+		t.Error("Expected a matching SET to satisfy the condition")
+	}
+	if _, ok := condition.regress(EffectBool{Key: 1, Value: false, Operator: SET}); ok {
+		t.Error("Expected a different SET to break the condition")
+	}
+}
+
+func TestRegressConditions_Irrelevant(t *testing.T) {
+	agent := CreateAgent(Goals{}, Actions{})
+	SetState[int](&agent, 1, 0)
+
+	conditions := Conditions{&Condition[int]{Key: 1, Value: 100, Operator: UPPER_OR_EQUAL}}
+
+	// Substracting moves away from the condition
+	action := &Action{effects: Effects{Effect[int]{Key: 1, Value: 10, Operator: SUBSTRACT}}}
+	if _, ok := regressConditions(agent.w, conditions, action); ok {
+		t.Error("Expected action moving away from the condition to be irrelevant")
+	}
+
+	// Another key is not relevant
+	action = &Action{effects: Effects{Effect[int]{Key: 2, Value: 10, Operator: SET}}}
+	if _, ok := regressConditions(agent.w, conditions, action); ok {
+		t.Error("Expected action on another key to be irrelevant")
+	}
+}
+
+func TestRegressConditions_AddsPreconditions(t *testing.T) {
+	agent := CreateAgent(Goals{}, Actions{})
+	SetState[bool](&agent, 1, false)
+	SetState[bool](&agent, 2, false)
+
+	conditions := Conditions{&ConditionBool{Key: 2, Value: true, Operator: EQUAL}}
+	precondition := &ConditionBool{Key: 1, Value: true, Operator: EQUAL}
+	action := &Action{
+		conditions: Conditions{precondition},
+		effects:    Effects{EffectBool{Key: 2, Value: true, Operator: SET}},
+	}
+
+	regressed, ok := regressConditions(agent.w, conditions, action)
+	if !ok {
+		t.Fatal("Expected action to be relevant")
+	}
+	if len(regressed) != 1 || regressed[0] != precondition {
+		t.Errorf("Expected only the action's precondition to remain, got %v", regressed)
+	}
+}
+
+func TestFindPlan_Backward(t *testing.T) {
+	actions := Actions{}
+	actions.AddAction("get_wood", 2.0, false, Conditions{}, Effects{
+		EffectBool{Key: 1, Value: true, Operator: SET},
+	})
+	actions.AddAction("get_matches", 1.0, false, Conditions{}, Effects{
+		EffectBool{Key: 2, Value: true, Operator: SET},
+	})
+	actions.AddAction("make_fire", 1.0, false, Conditions{
+		&ConditionBool{Key: 1, Value: true, Operator: EQUAL},
+		&ConditionBool{Key: 2, Value: true, Operator: EQUAL},
+	}, Effects{
+		EffectBool{Key: 3, Value: true, Operator: SET},
+	})
+	actions.AddAction("collect_coins", 1.0, true, Conditions{}, Effects{
+		Effect[int]{Key: 4, Value: 10, Operator: ADD},
+	})
+
+	goals := Goals{
+		"stay_warm": {
+			Conditions: Conditions{
+				&ConditionBool{Key: 3, Value: true, Operator: EQUAL},
+			},
+			PriorityFn: func(sensors Sensors) float32 {
+				return 1.0
+			},
+		},
+	}
+
+	agent := CreateAgent(goals, actions)
+	SetState[bool](&agent, 1, false)
+	SetState[bool](&agent, 2, false)
+	SetState[bool](&agent, 3, false)
+	SetState[int](&agent, 4, 0)
+
+	result, err := FindPlan(agent, PlanOptions{MaxDepth: 10, Direction: BACKWARD})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(result.Plan) != 4 {
+		t.Fatalf("Expected plan with 4 actions (root + 3), got %d", len(result.Plan))
+	}
+	if result.Plan[3].name != "make_fire" {
+		t.Errorf("Expected last action to be 'make_fire', got '%s'", result.Plan[3].name)
+	}
+	if result.Plan.GetTotalCost() != 4.0 {
+		t.Errorf("Expected total cost 4.0, got %f", result.Plan.GetTotalCost())
+	}
+	for _, action := range result.Plan {
+		if action.name == "collect_coins" {
+			t.Error("Expected irrelevant action not to be part of the plan")
+		}
+	}
+}
+
+func TestFindPlan_BackwardNumeric(t *testing.T) {
+	actions := Actions{}
+	actions.AddAction("increment", 1.0, true, Conditions{}, Effects{
+		Effect[int]{Key: 1, Value: 10, Operator: ADD},
+	})
+
+	goals := Goals{
+		"reach_30": {
+			Conditions: Conditions{
+				&Condition[int]{Key: 1, Value: 30, Operator: EQUAL},
+			},
+			PriorityFn: func(sensors Sensors) float32 {
+				return 1.0
+			},
+		},
+	}
+
+	agent := CreateAgent(goals, actions)
+	SetState[int](&agent, 1, 0)
+
+	result, err := FindPlan(agent, PlanOptions{MaxDepth: 10, Direction: BACKWARD})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.Plan) != 4 {
+		t.Errorf("Expected plan with 4 actions (including root), got %d", len(result.Plan))
+	}
+
+	_, err = FindPlan(agent, PlanOptions{MaxDepth: 2, Direction: BACKWARD})
+	if !errors.Is(err, ErrMaxDepth) {
+		t.Errorf("Expected ErrMaxDepth, got %v", err)
+	}
+}
+
+func TestFindPlan_BackwardUnsigned(t *testing.T) {
+	actions := Actions{}
+	actions.AddAction("fill", 1.0, true, Conditions{}, Effects{
+		Effect[uint8]{Key: 1, Value: 10, Operator: ADD},
+	})
+
+	goals := Goals{
+		"reach_5": {
+			Conditions: Conditions{
+				&Condition[uint8]{Key: 1, Value: 5, Operator: UPPER_OR_EQUAL},
+			},
+			PriorityFn: func(sensors Sensors) float32 {
+				return 1.0
+			},
+		},
+	}
+
+	agent := CreateAgent(goals, actions)
+	SetState[uint8](&agent, 1, 0)
+
+	for _, direction := range []direction{FORWARD, BACKWARD} {
+		result, err := FindPlan(agent, PlanOptions{MaxDepth: 5, Direction: direction})
+		if err != nil {
+			t.Fatalf("Unexpected error in direction %d: %v", direction, err)
+		}
+		if len(result.Plan) != 2 {
+			t.Errorf("Expected plan with 2 actions (including root) in direction %d, got %d", direction, len(result.Plan))
+		}
+	}
+}
+
+func TestFindPlan_BackwardMissingState(t *testing.T) {
+	tests := []struct {
+		name      string
+		effect    Effect[int]
+		condition *Condition[int]
+	}{
+		{"ADD", Effect[int]{Key: 2, Value: 1, Operator: ADD}, &Condition[int]{Key: 2, Value: 3, Operator: UPPER_OR_EQUAL}},
+		{"SUBSTRACT", Effect[int]{Key: 2, Value: 1, Operator: SUBSTRACT}, &Condition[int]{Key: 2, Value: -3, Operator: LOWER_OR_EQUAL}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// The state 2 is created by the first chop
+			actions := Actions{}
+			actions.AddAction("take_axe", 1, false, Conditions{}, Effects{
+				EffectBool{Key: 1, Value: true, Operator: SET},
+			})
+			actions.AddAction("chop", 1, true, Conditions{
+				&ConditionBool{Key: 1, Value: true, Operator: EQUAL},
+			}, Effects{test.effect})
+
+			goals := Goals{
+				"gather": {
+					Conditions: Conditions{test.condition},
+					PriorityFn: func(sensors Sensors) float32 { return 1 },
+				},
+			}
+
+			agent := CreateAgent(goals, actions)
+			SetState[bool](&agent, 1, false)
+
+			forward, err := FindPlan(agent, PlanOptions{MaxDepth: 10})
+			if err != nil {
+				t.Fatalf("Unexpected error in direction FORWARD: %v", err)
+			}
+			backward, err := FindPlan(agent, PlanOptions{MaxDepth: 10, Direction: BACKWARD})
+			if err != nil {
+				t.Fatalf("Unexpected error in direction BACKWARD: %v", err)
+			}
+
+			expected := []string{"take_axe", "chop", "chop", "chop"}
+			if names := planNames(forward.Plan); !slices.Equal(names, expected) {
+				t.Errorf("Expected the FORWARD plan %v, got %v", expected, names)
+			}
+			if names := planNames(backward.Plan); !slices.Equal(names, expected) {
+				t.Errorf("Expected the BACKWARD plan %v, got %v", expected, names)
+			}
+		})
+	}
+}
+
+func TestFindPlan_BackwardUnreachable(t *testing.T) {
+	actions := Actions{}
+	actions.AddAction("double", 1.0, true, Conditions{}, Effects{
+		Effect[int]{Key: 1, Value: 2, Operator: MULTIPLY},
+	})
+
+	goals := Goals{
+		"reach_8": {
+			Conditions: Conditions{
+				&Condition[int]{Key: 1, Value: 8, Operator: EQUAL},
+			},
+			PriorityFn: func(sensors Sensors) float32 {
+				return 1.0
+			},
+		},
+	}
+
+	agent := CreateAgent(goals, actions)
+	SetState[int](&agent, 1, 1)
+
+	_, err := FindPlan(agent, PlanOptions{MaxDepth: 10, Direction: BACKWARD})
+	if !errors.Is(err, ErrGoalUnreachable) {
+		t.Errorf("Expected ErrGoalUnreachable, got %v", err)
+	}
+}
+
+func TestFindPlan_BackwardExpandsRelevantActions(t *testing.T) {
+	actions := Actions{}
+	for i := 0; i < 100; i++ {
+		actions.AddAction(fmt.Sprintf("noise_%d", i), 1.0, false, Conditions{}, Effects{
+			EffectBool{Key: StateKey(10 + i), Value: true, Operator: SET},
+		})
+	}
+	actions.AddAction("target", 1.0, false, Conditions{}, Effects{
+		EffectBool{Key: 1, Value: true, Operator: SET},
+	})
+
+	goals := Goals{
+		"goal": {
+			Conditions: Conditions{
+				&ConditionBool{Key: 1, Value: true, Operator: EQUAL},
+			},
+			PriorityFn: func(sensors Sensors) float32 {
+				return 1.0
+			},
+		},
+	}
+
+	agent := CreateAgent(goals, actions)
+	SetState[bool](&agent, 1, false)
+
+	forward, err := FindPlan(agent, PlanOptions{MaxDepth: 5})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	backward, err := FindPlan(agent, PlanOptions{MaxDepth: 5, Direction: BACKWARD})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if backward.NodesGenerated != 1 {
+		t.Errorf("Expected backward search to generate 1 node, got %d", backward.NodesGenerated)
+	}
+	if backward.NodesGenerated >= forward.NodesGenerated {
+		t.Errorf("Expected backward search to generate less nodes than forward (%d >= %d)", backward.NodesGenerated, forward.NodesGenerated)
+	}
+}
+
+func TestCanonicalConditions(t *testing.T) {
+	fn := &ConditionFn{Key: 9, CheckFn: func(sensors Sensors) bool { return true }}
+	conditions := Conditions{
+		&ConditionBool{Key: 2, Value: true, Operator: EQUAL},
+		&Condition[int]{Key: 1, Value: 5, Operator: UPPER_OR_EQUAL},
+		fn,
+		&Condition[int]{Key: 1, Value: 8, Operator: UPPER_OR_EQUAL},
+		&ConditionBool{Key: 2, Value: true, Operator: EQUAL},
+		&Condition[int]{Key: 1, Value: 20, Operator: LOWER},
+		&Condition[int]{Key: 1, Value: 12, Operator: LOWER},
+		fn,
+	}
+
+	canonical := canonicalConditions(conditions)
+	expected := Conditions{
+		&Condition[int]{Key: 1, Value: 12, Operator: LOWER},
+		&Condition[int]{Key: 1, Value: 8, Operator: UPPER_OR_EQUAL},
+		&ConditionBool{Key: 2, Value: true, Operator: EQUAL},
+		fn,
+	}
+	if !slices.EqualFunc(canonical, expected, sameCondition) {
+		t.Errorf("Expected %v, got %v", expected, canonical)
+	}
+
+	// The order of the conditions does not matter
+	slices.Reverse(conditions)
+	if reversed := canonicalConditions(conditions); hashConditions(reversed) != hashConditions(canonical) || !slices.EqualFunc(reversed, canonical, sameCondition) {
+		t.Errorf("Expected %v, got %v", canonical, reversed)
+	}
+}
+
+func TestFindPlan_BackwardDuplicateConditions(t *testing.T) {
+	actions := Actions{}
+	for i := 1; i <= 3; i++ {
+		actions.AddAction(fmt.Sprintf("add_%d", i), float32(i), true, Conditions{
+			&ConditionBool{Key: 2, Value: true, Operator: EQUAL},
+		}, Effects{
+			Effect[int]{Key: 1, Value: i, Operator: ADD},
+		})
+	}
+
+	tests := []struct {
+		name      string
+		condition ConditionInterface
+		err       error
+	}{
+		{"reachable", &ConditionBool{Key: 2, Value: true, Operator: EQUAL}, nil},
+		{"unreachable", &ConditionBool{Key: 3, Value: true, Operator: EQUAL}, ErrGoalUnreachable},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			goals := Goals{
+				"count": {
+					Conditions: Conditions{
+						&Condition[int]{Key: 1, Value: 30, Operator: UPPER_OR_EQUAL},
+						test.condition,
+					},
+					PriorityFn: func(sensors Sensors) float32 {
+						return 1.0
+					},
+				},
+			}
+
+			agent := CreateAgent(goals, actions)
+			SetState[int](&agent, 1, 0)
+			SetState[bool](&agent, 2, true)
+			SetState[bool](&agent, 3, false)
+
+			result, err := FindPlan(agent, PlanOptions{MaxDepth: 12, Direction: BACKWARD})
+			if !errors.Is(err, test.err) {
+				t.Fatalf("Expected %v, got %v", test.err, err)
+			}
+			// The regressed thresholds of key 1 are shared by the nodes, whatever the order of the actions
+			if result.NodesExpanded > 100 {
+				t.Errorf("Expected the duplicate conditions to be expanded once, got %d nodes expanded", result.NodesExpanded)
+			}
+		})
+	}
+}
+
+func TestFindPlan_BackwardReopensDepthLimitedConditions(t *testing.T) {
+	// The cheap path regresses to 1 == 1 at the depth limit, the expensive one before it
+	actions := Actions{}
+	for _, step := range []struct {
+		name     string
+		cost     float32
+		from, to int
+	}{
+		{"a1", 1, 0, 1},
+		{"a2", 1, 1, 2},
+		{"a3", 1, 2, 3},
+		{"a4", 1, 3, 4},
+		{"c", 5, 1, 9},
+		{"d", 5, 9, 4},
+	} {
+		actions.AddAction(step.name, step.cost, false, Conditions{
+			&Condition[int]{Key: 1, Value: step.from, Operator: EQUAL},
+		}, Effects{
+			Effect[int]{Key: 1, Value: step.to, Operator: SET},
+		})
+	}
+
+	goals := Goals{
+		"reach_4": {
+			Conditions: Conditions{&Condition[int]{Key: 1, Value: 4, Operator: EQUAL}},
+			PriorityFn: func(sensors Sensors) float32 { return 1 },
+		},
+	}
+
+	agent := CreateAgent(goals, actions)
+	SetState[int](&agent, 1, 0)
+
+	result, err := FindPlan(agent, PlanOptions{MaxDepth: 3, Direction: BACKWARD})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if names := planNames(result.Plan); !slices.Equal(names, []string{"a1", "c", "d"}) {
+		t.Errorf("Expected the plan [a1 c d], got %v", names)
+	}
+}
@@ -0,0 +1,163 @@
+package goapai
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// RepairPlan repairs the plan of a goal once the agent's world state changed, instead of planning it again.
+//
+// The plan holds the actions remaining to perform, starting with the next one. It is replayed from the
+// agent's world state: the steps that can still be performed are kept as a prefix, then the repair looks
+// for the first step of the rest of the plan from which it still reaches the goal, either directly or
+// through a bridge: a plan searched with options from the world state after the prefix, to the conditions
+// the suffix requires. The steps skipped in between are dropped. At most 3 bridges are searched, from
+// the longest suffix, each expanding at most a quarter of MaxNodes, or 1024 nodes if MaxNodes is not set. The suffix conditions are computed by
+// regressing the goal's conditions through the steps, as with the backward search: a step with effects
+// that cannot be regressed (MULTIPLY, DIVIDE, custom conditions) ends the suffixes considered. The
+// repaired plan is limited to MaxDepth actions.
+//
+// If no bridge is found, or if the goal has a Task, the goal is planned from scratch as with FindPlan.
+// PlanResult.Repaired is true if the plan was repaired, and its first action is the starting node.
+// It returns ErrUnknownGoal if the goal does not exist.
+//
+// Example:
+//
+//	if err := remaining.Validate(&entity.agent); err != nil {
+//	    result, err := goapai.RepairPlan(entity.agent, goalName, remaining, goapai.PlanOptions{MaxDepth: 10})
+//	}
+func RepairPlan(agent Agent, goalName GoalName, plan Plan, options PlanOptions) (PlanResult, error) {
+	start := time.Now()
+
+	goal, ok := agent.goals[goalName]
+	if !ok {
+		return PlanResult{Plan: Plan{}}, fmt.Errorf("%w: %q", ErrUnknownGoal, goalName)
+	}
+
+	agent.w = agent.planningWorld()
+	result := PlanResult{GoalName: goalName, Plan: Plan{}}
+	if goal.Task == nil {
+		result.Plan, result.Repaired = repairPlan(agent.w, goal, agent.actions, plan, options, &result.SearchStats)
+	}
+
+	var err error
+	if !result.Repaired {
+		search := agent.createSearch(goalName, options)
+		for !search.expand() {
+		}
+
+		var stats SearchStats
+		result.Plan, stats, err = search.result()
+		result.addStats(stats)
+		if err != nil {
+			result.Incomplete = len(result.Plan) > 0
+			err = fmt.Errorf("goal %q: %w", goalName, err)
+		}
+	}
+	result.Elapsed = time.Since(start)
+
+	return result, err
+}
+
+const (
+	repairBridges     = 3    // Maximum number of bridges searched before planning from scratch
+	repairBridgeNodes = 1024 // Nodes expanded by a bridge if PlanOptions.MaxNodes is not set
+)
+
+// repairPlan returns the repaired plan from the world w, made of the valid prefix of the plan, a bridge
+// and the suffix of the plan still reaching the goal. The boolean is false if the plan cannot be repaired.
+func repairPlan(w world, goal goalInterface, actions Actions, plan Plan, options PlanOptions, stats *SearchStats) (Plan, bool) {
+	plan = plan.steps()
+
+	prefixWorld, err := plan.replay(w.clone(), nil)
+	prefix := plan
+	var stepErr *PlanStepError
+	if errors.As(err, &stepErr) {
+		prefix = plan[:stepErr.Step]
+		prefixWorld, _ = prefix.replay(w.clone(), nil)
+	}
+
+	required, regressed := regressSuffixes(goal, plan)
+	first := max(len(prefix), regressed)
+
+	// A suffix whose conditions already hold needs no bridge, the longest one skipping the fewest steps
+	for k := first; k <= len(plan); k++ {
+		if required[k].Check(prefixWorld) {
+			if repaired, ok := joinRepair(prefixWorld, goal, prefix, Plan{&Action{start: true}}, plan[k:]); ok {
+				return repaired, true
+			}
+		}
+	}
+
+	bridgeOptions := options
+	bridgeOptions.MaxNodes = repairBridgeNodes
+	if options.MaxNodes > 0 {
+		bridgeOptions.MaxNodes = max(options.MaxNodes/(repairBridges+1), 1)
+	}
+
+	for k, bridges := first, 0; k <= len(plan) && bridges < repairBridges; k++ {
+		bridgeOptions.MaxDepth = options.MaxDepth - len(prefix) - len(plan[k:])
+		if bridgeOptions.MaxDepth <= 0 {
+			continue
+		}
+		bridges++
+
+		var search searcher
+		if options.Direction == BACKWARD {
+			search = createBackwardSearch(prefixWorld, goalInterface{Conditions: required[k]}, actions, bridgeOptions)
+		} else {
+			search = createForwardSearch(prefixWorld, goalInterface{Conditions: required[k]}, actions, bridgeOptions)
+		}
+		for !search.expand() {
+		}
+
+		bridge, bridgeStats, err := search.result()
+		stats.addStats(bridgeStats)
+		if err != nil {
+			continue
+		}
+		if repaired, ok := joinRepair(prefixWorld, goal, prefix, bridge, plan[k:]); ok {
+			return repaired, true
+		}
+	}
+
+	return nil, false
+}
+
+// regressSuffixes returns the conditions that must hold before each step of the plan, so that the
+// rest of the plan reaches the goal: the last entry holds the goal's conditions. The regression stops
+// at the last step whose effects cannot be regressed: the index returned is the first entry computed.
+func regressSuffixes(goal goalInterface, plan Plan) ([]Conditions, int) {
+	required := make([]Conditions, len(plan)+1)
+	required[len(plan)] = goal.Conditions
+
+	for k := len(plan) - 1; k >= 0; k-- {
+		conditions := make(Conditions, 0, len(required[k+1])+len(plan[k].conditions))
+		for _, condition := range required[k+1] {
+			regressed, ok := regressCondition(condition, plan[k])
+			if !ok {
+				return required, k + 1
+			}
+			if regressed != nil {
+				conditions = append(conditions, regressed)
+			}
+		}
+		required[k] = append(conditions, plan[k].conditions...)
+	}
+
+	return required, 0
+}
+
+// joinRepair returns the plan made of the prefix, the bridge found from the world prefixWorld
+// and the suffix. The boolean is false if the bridge and the suffix do not reach the goal.
+func joinRepair(prefixWorld world, goal goalInterface, prefix Plan, bridge Plan, suffix Plan) (Plan, bool) {
+	tail := Plan(slices.Concat(bridge.steps(), suffix))
+	w, err := tail.replay(prefixWorld.clone(), nil)
+	if err != nil || countMissingGoal(goal, w) > 0 {
+		return nil, false
+	}
+
+	return slices.Concat(Plan{&Action{start: true}}, prefix, tail), true
+}
@@ -0,0 +1,155 @@
+package goapai
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRepairPlan(t *testing.T) {
+	agent := createTraceAgent(t)
+	chop := agent.actions.GetAction("chop_wood")
+	drop := &Action{name: "drop_axe", effects: Effects{EffectBool{Key: 1, Value: false, Operator: SET}}}
+	scale := &Action{name: "scale", effects: Effects{Effect[int]{Key: 2, Value: 2, Operator: MULTIPLY}}}
+
+	tests := []struct {
+		name     string
+		hasAxe   bool
+		wood     int
+		plan     Plan
+		maxDepth int
+		expected string
+		repaired bool
+		err      error
+	}{
+		{
+			name:     "still valid",
+			hasAxe:   true,
+			wood:     0,
+			plan:     Plan{&Action{start: true}, chop, chop},
+			maxDepth: 5,
+			expected: "chop_wood,chop_wood",
+			repaired: true,
+		},
+		{
+			name:     "bridge to the first step",
+			hasAxe:   false,
+			wood:     0,
+			plan:     Plan{chop, chop},
+			maxDepth: 5,
+			expected: "take_axe,chop_wood,chop_wood",
+			repaired: true,
+		},
+		{
+			name:     "prefix kept",
+			hasAxe:   true,
+			wood:     0,
+			plan:     Plan{chop, drop, chop},
+			maxDepth: 5,
+			expected: "chop_wood,drop_axe,take_axe,chop_wood",
+			repaired: true,
+		},
+		{
+			name:     "step not regressed",
+			hasAxe:   false,
+			wood:     0,
+			plan:     Plan{scale, chop, chop},
+			maxDepth: 5,
+			expected: "scale,take_axe,chop_wood,chop_wood",
+			repaired: true,
+		},
+		{
+			name:     "steps no longer needed",
+			hasAxe:   false,
+			wood:     2,
+			plan:     Plan{chop, chop},
+			maxDepth: 5,
+			expected: "",
+			repaired: true,
+		},
+		{
+			name:     "plan from scratch",
+			hasAxe:   false,
+			wood:     0,
+			plan:     Plan{drop, chop, chop},
+			maxDepth: 3,
+			expected: "take_axe,chop_wood,chop_wood",
+		},
+		{
+			name:     "depth limit",
+			hasAxe:   false,
+			wood:     0,
+			plan:     Plan{chop, chop},
+			maxDepth: 2,
+			err:      ErrMaxDepth,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			SetState[bool](&agent, 1, test.hasAxe)
+			SetState[int](&agent, 2, test.wood)
+
+			result, err := RepairPlan(agent, "gather_wood", test.plan, PlanOptions{MaxDepth: test.maxDepth})
+			if !errors.Is(err, test.err) {
+				t.Fatalf("Expected error %v, got %v", test.err, err)
+			}
+			if result.Repaired != test.repaired {
+				t.Errorf("Expected Repaired %v, got %v", test.repaired, result.Repaired)
+			}
+			if err != nil {
+				return
+			}
+
+			if names := strings.Join(planNames(result.Plan), ","); names != test.expected {
+				t.Errorf("Expected the plan %s, got %s", test.expected, names)
+			}
+			if !result.Plan[0].start {
+				t.Errorf("Expected the plan to start with the starting node, got %s", result.Plan[0].name)
+			}
+			if err := result.Plan.Validate(&agent); err != nil {
+				t.Errorf("Expected the plan to be valid, got %v", err)
+			}
+		})
+	}
+
+	if _, err := RepairPlan(agent, "rest", Plan{}, PlanOptions{MaxDepth: 5}); !errors.Is(err, ErrUnknownGoal) {
+		t.Errorf("Expected ErrUnknownGoal, got %v", err)
+	}
+}
+
+func TestRunner_Repair(t *testing.T) {
+	agent, getWood, makeFire := createFireAgent()
+	runner := CreateRunner(agent, PlanOptions{MaxDepth: 5, Repair: true})
+
+	// get_wood runs for 3 ticks, then make_fire becomes the next action
+	for i := 0; i < 3; i++ {
+		if err := runner.Tick(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if action := runner.GetCurrentAction(); action == nil || action.name != "make_fire" {
+		t.Fatalf("Expected make_fire to be the current action, got %v", action)
+	}
+
+	// The wood is stolen: get_wood is inserted before make_fire
+	SetState[bool](agent, 1, false)
+	if err := runner.Tick(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if names := strings.Join(planNames(runner.GetPlan()), ","); names != "get_wood,make_fire" {
+		t.Errorf("Expected the repaired plan get_wood,make_fire, got %s", names)
+	}
+
+	for i := 0; i < 5 && makeFire.started == 0; i++ {
+		if err := runner.Tick(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if getWood.started != 2 || makeFire.started != 1 {
+		t.Errorf("Expected get_wood started twice and make_fire once, got %d and %d", getWood.started, makeFire.started)
+	}
+	if fire, _ := GetState[bool](agent, 2); !fire {
+		t.Error("Expected the fire to be made")
+	}
+}
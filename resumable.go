@@ -0,0 +1,236 @@
+package goapai
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+type planMode uint8
+
+const (
+	planPrioritized planMode = iota
+	planFallback
+	planUtility
+)
+
+// Planner is a planning request that can be spread over several frames.
+//
+// Each call to Step or StepFor expands a bounded number of nodes, then returns, keeping the
+// state of the search (open set, explored world states) until the next call. Once Step or StepFor
+// returns true, the outcome is available through Result, identical to the one of FindPlan.
+//
+// The Planner works on a copy of the agent's world state taken by CreatePlanner, so the agent
+// can be updated between two steps. The GoalTimeout and Deadline options are wall-clock times:
+// the frames between two steps count towards them.
+//
+// Example:
+//
+//	planner := goapai.CreatePlanner(entity.agent, goapai.PlanOptions{MaxDepth: 10})
+//	// in the game loop
+//	if planner.StepFor(500 * time.Microsecond) {
+//	    result, err := planner.Result()
+//	}
+type Planner struct {
+	agent   Agent
+	options PlanOptions
+	mode    planMode
+
+	goals     []prioritizedGoal // Goals to plan, by descending priority
+	goalIndex int               // Index in goals of the goal being planned
+	search    searcher          // Search of the goal being planned, nil between two goals
+
+	bestUtility float32
+	found       bool
+
+	done   bool
+	result PlanResult
+	err    error
+}
+
+// PlanProgress describes the progress of a Planner.
+type PlanProgress struct {
+	GoalName   GoalName // Goal being planned, empty once the planning is done
+	GoalsDone  int      // Number of goals already planned
+	GoalsTotal int      // Number of goals to plan, depending on the PlanOptions
+	SearchStats
+}
+
+// CreatePlanner creates a Planner for the agent, configured with options like FindPlan.
+// No node is expanded until the first call to Step or StepFor.
+func CreatePlanner(agent Agent, options PlanOptions) *Planner {
+	agent.w = agent.planningWorld()
+
+	planner := &Planner{
+		agent:   agent,
+		options: options,
+		result:  PlanResult{Plan: Plan{}},
+	}
+
+	switch {
+	case options.UtilityFn != nil:
+		planner.mode = planUtility
+		planner.goals = agent.getSortedGoals()
+		if options.UtilityCandidates > 0 && options.UtilityCandidates < len(planner.goals) {
+			planner.goals = planner.goals[:options.UtilityCandidates]
+		}
+	case options.Fallback:
+		planner.mode = planFallback
+		planner.goals = agent.getSortedGoals()
+	default:
+		planner.mode = planPrioritized
+		if goalName, err := agent.getPrioritizedGoalName(); err == nil {
+			planner.goals = []prioritizedGoal{{name: goalName}}
+		}
+	}
+
+	if len(planner.goals) == 0 {
+		planner.finish(ErrNoGoalAvailable)
+	}
+
+	return planner
+}
+
+// Step expands at most the given number of nodes, and returns true once the planning is done.
+func (planner *Planner) Step(expansions int) bool {
+	start := time.Now()
+
+	for i := 0; i < expansions && !planner.done; i++ {
+		planner.expand()
+	}
+	planner.result.Elapsed += time.Since(start)
+
+	return planner.done
+}
+
+// StepFor expands nodes for about the given duration, and returns true once the planning is done.
+// At least one node is expanded, so that the planning always progresses.
+func (planner *Planner) StepFor(duration time.Duration) bool {
+	start := time.Now()
+
+	for !planner.done {
+		planner.expand()
+
+		if time.Since(start) >= duration {
+			break
+		}
+	}
+	planner.result.Elapsed += time.Since(start)
+
+	return planner.done
+}
+
+// Done returns true once the planning is done, and its outcome available through Result.
+func (planner *Planner) Done() bool {
+	return planner.done
+}
+
+// Progress returns the current goal of the Planner, and the statistics of the search so far.
+func (planner *Planner) Progress() PlanProgress {
+	progress := PlanProgress{
+		GoalsDone:   planner.goalIndex,
+		GoalsTotal:  len(planner.goals),
+		SearchStats: planner.result.SearchStats,
+	}
+
+	if planner.goalIndex < len(planner.goals) {
+		progress.GoalName = planner.goals[planner.goalIndex].name
+	}
+	if planner.search != nil {
+		progress.addStats(planner.search.getStats())
+	}
+
+	return progress
+}
+
+// Result returns the outcome of the planning, as returned by FindPlan.
+// Before the planning is done, the PlanResult is empty and the error is nil.
+func (planner *Planner) Result() (PlanResult, error) {
+	if !planner.done {
+		return PlanResult{Plan: Plan{}}, nil
+	}
+
+	return planner.result, planner.err
+}
+
+// run expands nodes until the planning is done.
+func (planner *Planner) run() (PlanResult, error) {
+	planner.Step(math.MaxInt)
+
+	return planner.Result()
+}
+
+// expand expands the next node of the current goal's search, starting the search if required.
+func (planner *Planner) expand() {
+	goal := planner.goals[planner.goalIndex]
+
+	if planner.search == nil {
+		options := planner.options
+		if planner.mode != planPrioritized {
+			options = options.goalOptions()
+		}
+		planner.search = planner.agent.createSearch(goal.name, options)
+	}
+
+	if !planner.search.expand() {
+		return
+	}
+
+	plan, stats, err := planner.search.result()
+	planner.search = nil
+	planner.goalIndex++
+	planner.result.addStats(stats)
+
+	switch planner.mode {
+	case planPrioritized:
+		planner.result.GoalName = goal.name
+		planner.result.Plan = plan
+		if err != nil {
+			planner.result.Incomplete = len(plan) > 0
+			planner.finish(fmt.Errorf("goal %q: %w", goal.name, err))
+			return
+		}
+		planner.finish(nil)
+		return
+	case planFallback:
+		if err == nil {
+			planner.result.GoalName = goal.name
+			planner.result.Plan = plan
+			planner.finish(nil)
+			return
+		}
+	case planUtility:
+		if err == nil {
+			utility := planner.options.UtilityFn(goal.priority, plan.GetTotalCost(), len(plan.steps()))
+			if !planner.found || utility > planner.bestUtility {
+				planner.result.GoalName = goal.name
+				planner.result.Plan = plan
+				planner.bestUtility = utility
+				planner.found = true
+			}
+		}
+	}
+
+	if err != nil {
+		if planner.options.checkInterruption() != nil {
+			planner.finish(fmt.Errorf("goal %q: %w", goal.name, err))
+			return
+		}
+		planner.result.Skipped = append(planner.result.Skipped, SkippedGoal{GoalName: goal.name, Priority: goal.priority, Err: err})
+	}
+
+	if planner.goalIndex < len(planner.goals) {
+		return
+	}
+
+	if planner.found {
+		planner.finish(nil)
+	} else {
+		planner.finish(fmt.Errorf("%w: none of the %d goals can be achieved", ErrGoalUnreachable, len(planner.goals)))
+	}
+}
+
+func (planner *Planner) finish(err error) {
+	planner.done = true
+	planner.err = err
+}
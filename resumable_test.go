@@ -0,0 +1,133 @@
+package goapai
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPlanner_Step(t *testing.T) {
+	agent := createBudgetAgent()
+	options := PlanOptions{MaxDepth: 2000}
+
+	expected, err := FindPlan(agent, options)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	planner := CreatePlanner(agent, options)
+	if planner.Done() {
+		t.Fatal("Expected planner not to be done before the first step")
+	}
+	if result, err := planner.Result(); err != nil || len(result.Plan) != 0 {
+		t.Errorf("Expected empty result before the planning is done, got %d actions, %v", len(result.Plan), err)
+	}
+
+	steps := 0
+	for !planner.Step(10) {
+		steps++
+
+		progress := planner.Progress()
+		if progress.GoalName != "reach_1000" || progress.GoalsTotal != 1 {
+			t.Fatalf("Expected progress on goal 'reach_1000', got %+v", progress)
+		}
+		if progress.NodesExpanded > steps*10 {
+			t.Fatalf("Expected at most %d expanded nodes, got %d", steps*10, progress.NodesExpanded)
+		}
+	}
+	if steps == 0 {
+		t.Error("Expected the planning to require several steps")
+	}
+
+	result, err := planner.Result()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.Plan) != len(expected.Plan) || result.Plan.GetTotalCost() != expected.Plan.GetTotalCost() {
+		t.Errorf("Expected the same plan as FindPlan, got %d actions (cost %f)", len(result.Plan), result.Plan.GetTotalCost())
+	}
+	if result.NodesExpanded != expected.NodesExpanded {
+		t.Errorf("Expected %d expanded nodes, got %d", expected.NodesExpanded, result.NodesExpanded)
+	}
+	if progress := planner.Progress(); progress.GoalsDone != 1 || progress.NodesExpanded != result.NodesExpanded {
+		t.Errorf("Expected final progress, got %+v", progress)
+	}
+}
+
+func TestPlanner_StepFor(t *testing.T) {
+	planner := CreatePlanner(createBudgetAgent(), PlanOptions{MaxDepth: 2000})
+
+	for !planner.StepFor(100 * time.Microsecond) {
+	}
+
+	result, err := planner.Result()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.Plan) < 2 {
+		t.Errorf("Expected a plan, got %d actions", len(result.Plan))
+	}
+	if result.Elapsed <= 0 {
+		t.Error("Expected elapsed time to be measured")
+	}
+}
+
+func TestPlanner_WorldCopy(t *testing.T) {
+	agent := createBudgetAgent()
+	planner := CreatePlanner(agent, PlanOptions{MaxDepth: 2000})
+	planner.Step(5)
+
+	// The agent can be updated between two steps
+	SetState[int](&agent, 1, 999)
+
+	for !planner.Step(100) {
+	}
+	result, err := planner.Result()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	w, err := result.Plan.replay(planner.agent.w.clone(), nil)
+	if err != nil || countMissingGoal(planner.agent.goals["reach_1000"], w) != 0 {
+		t.Error("Expected the plan to be computed from the world state at creation")
+	}
+	if value, _ := lookupState[int](&planner.agent.w, 1); value != 0 {
+		t.Errorf("Expected the planner's world not to be modified, got %d", value)
+	}
+}
+
+func TestPlanner_Fallback(t *testing.T) {
+	agent := createFallbackAgent()
+	options := PlanOptions{MaxDepth: 2000, Direction: BACKWARD, Fallback: true, GoalMaxNodes: 50}
+
+	planner := CreatePlanner(agent, options)
+	goals := map[GoalName]bool{}
+	for !planner.Step(1) {
+		goals[planner.Progress().GoalName] = true
+	}
+
+	result, err := planner.Result()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.GoalName != "get_wood" || len(result.Skipped) != 2 {
+		t.Errorf("Expected goal 'get_wood' after 2 skipped goals, got '%s' (%d skipped)", result.GoalName, len(result.Skipped))
+	}
+	if !goals["unreachable"] || !goals["reach_1000"] {
+		t.Errorf("Expected progress to report each goal, got %v", goals)
+	}
+}
+
+func TestPlanner_NoGoal(t *testing.T) {
+	planner := CreatePlanner(CreateAgent(Goals{}, Actions{}), PlanOptions{MaxDepth: 10})
+
+	if !planner.Done() {
+		t.Fatal("Expected planner to be done without goal")
+	}
+	if _, err := planner.Result(); !errors.Is(err, ErrNoGoalAvailable) {
+		t.Errorf("Expected ErrNoGoalAvailable, got %v", err)
+	}
+	if !planner.Step(1) || !planner.StepFor(time.Millisecond) {
+		t.Error("Expected steps to be no-op once done")
+	}
+}
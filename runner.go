@@ -0,0 +1,287 @@
+package goapai
+
+import "slices"
+
+// ActionStatus is the execution status of an action, returned by its Executor.
+type ActionStatus uint8
+
+const (
+	RUNNING ActionStatus = iota
+	SUCCESS
+	FAILURE
+)
+
+// Executor runs an Action in the game world.
+//
+// Start is called once when the action becomes the current step of the plan, then Tick is
+// called every Runner tick while the status is RUNNING. Abort is called if the plan is
+// dropped while the action is still running (replanning, higher priority goal).
+type Executor interface {
+	Start(agent *Agent) ActionStatus
+	Tick(agent *Agent) ActionStatus
+	Abort(agent *Agent)
+}
+
+// SetExecutor attaches the Executor used by a Runner to perform the action.
+// An action without executor is considered successful as soon as it starts.
+func (action *Action) SetExecutor(executor Executor) {
+	action.executor = executor
+}
+
+// GetAction returns the action with the given name, or nil if it does not exist.
+func (actions Actions) GetAction(name string) *Action {
+	for _, action := range actions {
+		if action.name == name {
+			return action
+		}
+	}
+
+	return nil
+}
+
+// Runner executes the plans of an Agent, one step after another.
+//
+// On each Tick, the current action is advanced through its Executor. The Runner requests
+// a new plan when there is none, when an action fails, when the conditions of the next
+// action do not hold anymore in the agent's world state, or when another goal becomes
+// the prioritized one.
+type Runner struct {
+	agent    *Agent
+	options  PlanOptions
+	goalName GoalName
+	plan     Plan
+	step     int
+	running  bool
+
+	// Goals ranked above goalName with Fallback, and the world state hash, when last found unachievable,
+	// or candidate goals with UtilityFn when goalName was selected
+	checkedGoals []GoalName
+	checkedHash  uint64
+}
+
+// CreateRunner creates a Runner for the agent, planning with the given options.
+func CreateRunner(agent *Agent, options PlanOptions) *Runner {
+	return &Runner{
+		agent:   agent,
+		options: options,
+	}
+}
+
+// GetGoalName returns the goal of the plan being executed.
+func (runner *Runner) GetGoalName() GoalName {
+	return runner.goalName
+}
+
+// GetPlan returns the plan being executed.
+func (runner *Runner) GetPlan() Plan {
+	return runner.plan
+}
+
+// GetCurrentAction returns the action being executed, or nil if there is none.
+func (runner *Runner) GetCurrentAction() *Action {
+	if runner.step < len(runner.plan) {
+		return runner.plan[runner.step]
+	}
+
+	return nil
+}
+
+// Abort stops the current action, and drops the plan. The next Tick will replan.
+func (runner *Runner) Abort() {
+	if runner.running {
+		if executor := runner.plan[runner.step].executor; executor != nil {
+			executor.Abort(runner.agent)
+		}
+	}
+
+	runner.plan = nil
+	runner.step = 0
+	runner.running = false
+}
+
+// Tick advances the execution of the plan.
+//
+// The current action is started or ticked through its Executor. Once it succeeds, the
+// next tick moves to the following action of the plan, after checking its conditions
+// against the agent's world state. It returns the error of the planner if a new plan
+// was required and could not be found.
+//
+// With PlanOptions.Fallback, the plan is dropped once a goal with a higher priority than
+// its goal is achievable. With PlanOptions.UtilityFn, it is dropped once the candidate goals
+// changed, rather than the prioritized one.
+//
+// With PlanOptions.Repair, a plan whose next action cannot be performed anymore is repaired
+// with RepairPlan, rather than planned again.
+//
+// With PlanOptions.PartialPlan, an incomplete plan is executed as well, and a new plan is
+// requested once its last action succeeded.
+//
+// A composite action is refined once it becomes the current action: it is replaced in the plan
+// by its child actions, or by the sub-plan of its sub-goal.
+func (runner *Runner) Tick() error {
+	if runner.plan != nil && runner.goalChanged() {
+		runner.Abort()
+	}
+
+	if runner.plan == nil || runner.step >= len(runner.plan) {
+		if err := runner.replan(); err != nil {
+			return err
+		}
+	}
+	if runner.step >= len(runner.plan) {
+		return nil
+	}
+
+	for !runner.running {
+		if !runner.plan[runner.step].conditions.Check(runner.agent.w) {
+			if runner.options.Repair {
+				return runner.repair()
+			}
+			runner.Abort()
+			return runner.replan()
+		}
+		if !runner.plan[runner.step].IsComposite() {
+			break
+		}
+
+		if err := runner.refine(); err != nil {
+			runner.Abort()
+			return err
+		}
+		if runner.step >= len(runner.plan) {
+			return nil
+		}
+	}
+
+	action := runner.plan[runner.step]
+	status := SUCCESS
+	if !runner.running {
+		if action.executor != nil {
+			status = action.executor.Start(runner.agent)
+		}
+		runner.running = true
+	} else if action.executor != nil {
+		status = action.executor.Tick(runner.agent)
+	}
+
+	switch status {
+	case RUNNING:
+		return nil
+	case FAILURE:
+		runner.running = false
+		runner.Abort()
+		return runner.replan()
+	}
+
+	runner.running = false
+	runner.step++
+
+	return nil
+}
+
+// repair replaces the remaining actions of the plan by their repair, see RepairPlan.
+func (runner *Runner) repair() error {
+	remaining := runner.plan[runner.step:]
+	runner.plan = nil
+	runner.step = 0
+	runner.running = false
+
+	result, err := RepairPlan(*runner.agent, runner.goalName, remaining, runner.options)
+	if err != nil && !result.Incomplete {
+		return err
+	}
+
+	runner.plan = result.Plan
+	runner.step = len(result.Plan) - len(result.Plan.steps())
+
+	return nil
+}
+
+func (runner *Runner) replan() error {
+	runner.plan = nil
+	runner.step = 0
+	runner.running = false
+
+	result, err := FindPlan(*runner.agent, runner.options)
+	runner.goalName = result.GoalName
+	if err != nil && !result.Incomplete {
+		return err
+	}
+
+	runner.plan = result.Plan
+	runner.step = len(result.Plan) - len(result.Plan.steps())
+	switch {
+	case runner.options.UtilityFn != nil:
+		runner.checkedGoals = runner.utilityCandidates()
+	case runner.options.Fallback:
+		// The goals ranked above the one selected were all found unachievable
+		runner.checkedGoals, _ = runner.goalsAbove()
+		runner.checkedHash = runner.agent.w.hash
+	}
+
+	return nil
+}
+
+// goalChanged returns true if FindPlan would now select another goal than the one of the plan.
+//
+// With Fallback, the goals ranked above the goal of the plan were not achievable: the plan is only
+// dropped once one of them becomes achievable. They are planned again only when they or the world
+// state changed since the last check. With UtilityFn, the plan is dropped once the candidate goals
+// changed, as the utilities of the candidates are only known by planning them.
+func (runner *Runner) goalChanged() bool {
+	if runner.options.UtilityFn != nil {
+		return !slices.Equal(runner.utilityCandidates(), runner.checkedGoals)
+	}
+	if !runner.options.Fallback {
+		goalName, err := runner.agent.getPrioritizedGoalName()
+		return err != nil || goalName != runner.goalName
+	}
+
+	above, ok := runner.goalsAbove()
+	if !ok {
+		return true
+	}
+	if runner.agent.w.hash == runner.checkedHash && slices.Equal(above, runner.checkedGoals) {
+		return false
+	}
+
+	runner.checkedGoals = above
+	runner.checkedHash = runner.agent.w.hash
+	for _, goalName := range above {
+		if runner.agent.isAchievable(goalName, runner.options) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// goalsAbove returns the goals ranked above the goal of the plan, by descending priority.
+// The boolean is false if the goal of the plan has no priority anymore.
+func (runner *Runner) goalsAbove() ([]GoalName, bool) {
+	var above []GoalName
+	for _, goal := range runner.agent.getSortedGoals() {
+		if goal.name == runner.goalName {
+			return above, true
+		}
+		above = append(above, goal.name)
+	}
+
+	return nil, false
+}
+
+// utilityCandidates returns the goals planned by FindPlan with UtilityFn, sorted by name.
+func (runner *Runner) utilityCandidates() []GoalName {
+	goals := runner.agent.getSortedGoals()
+	if runner.options.UtilityCandidates > 0 && runner.options.UtilityCandidates < len(goals) {
+		goals = goals[:runner.options.UtilityCandidates]
+	}
+
+	candidates := make([]GoalName, 0, len(goals))
+	for _, goal := range goals {
+		candidates = append(candidates, goal.name)
+	}
+	slices.Sort(candidates)
+
+	return candidates
+}
@@ -0,0 +1,350 @@
+package goapai
+
+import (
+	"errors"
+	"testing"
+)
+
+type testExecutor struct {
+	ticks    int // Number of ticks before completion
+	status   ActionStatus
+	onDone   func(agent *Agent)
+	started  int
+	aborted  int
+	progress int
+}
+
+func (executor *testExecutor) Start(agent *Agent) ActionStatus {
+	executor.started++
+	executor.progress = 0
+
+	return executor.Tick(agent)
+}
+
+func (executor *testExecutor) Tick(agent *Agent) ActionStatus {
+	if executor.progress < executor.ticks {
+		executor.progress++
+		return RUNNING
+	}
+	if executor.status == SUCCESS && executor.onDone != nil {
+		executor.onDone(agent)
+	}
+
+	return executor.status
+}
+
+func (executor *testExecutor) Abort(agent *Agent) {
+	executor.aborted++
+}
+
+func createFireAgent() (*Agent, *testExecutor, *testExecutor) {
+	actions := Actions{}
+	actions.AddAction("get_wood", 1.0, false, Conditions{}, Effects{
+		EffectBool{Key: 1, Value: true, Operator: SET},
+	})
+	actions.AddAction("make_fire", 1.0, false, Conditions{
+		&ConditionBool{Key: 1, Value: true, Operator: EQUAL},
+	}, Effects{
+		EffectBool{Key: 2, Value: true, Operator: SET},
+	})
+
+	goals := Goals{
+		"stay_warm": {
+			Conditions: Conditions{
+				&ConditionBool{Key: 2, Value: true, Operator: EQUAL},
+			},
+			PriorityFn: func(sensors Sensors) float32 {
+				return 1.0
+			},
+		},
+	}
+
+	agent := CreateAgent(goals, actions)
+	SetState[bool](&agent, 1, false)
+	SetState[bool](&agent, 2, false)
+
+	getWood := &testExecutor{ticks: 2, status: SUCCESS, onDone: func(agent *Agent) {
+		State[bool]{Key: 1, Value: true}.Store(&agent.w)
+	}}
+	makeFire := &testExecutor{ticks: 0, status: SUCCESS, onDone: func(agent *Agent) {
+		State[bool]{Key: 2, Value: true}.Store(&agent.w)
+	}}
+	actions.GetAction("get_wood").SetExecutor(getWood)
+	actions.GetAction("make_fire").SetExecutor(makeFire)
+
+	return &agent, getWood, makeFire
+}
+
+func TestActions_GetAction(t *testing.T) {
+	actions := Actions{}
+	actions.AddAction("action1", 1.0, false, Conditions{}, Effects{})
+
+	if action := actions.GetAction("action1"); action == nil || action.name != "action1" {
+		t.Error("Expected to find 'action1'")
+	}
+	if action := actions.GetAction("unknown"); action != nil {
+		t.Error("Expected nil for unknown action")
+	}
+}
+
+func TestRunner_Tick_CompletesPlan(t *testing.T) {
+	agent, getWood, makeFire := createFireAgent()
+	runner := CreateRunner(agent, PlanOptions{MaxDepth: 10})
+
+	for i := 0; i < 2; i++ {
+		if err := runner.Tick(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if runner.GetCurrentAction().name != "get_wood" {
+			t.Fatalf("Tick %d: expected current action 'get_wood', got '%s'", i, runner.GetCurrentAction().name)
+		}
+	}
+	if runner.GetGoalName() != "stay_warm" {
+		t.Errorf("Expected goal 'stay_warm', got '%s'", runner.GetGoalName())
+	}
+
+	// get_wood completes on its third tick
+	if err := runner.Tick(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if runner.GetCurrentAction().name != "make_fire" {
+		t.Fatalf("Expected current action 'make_fire', got '%s'", runner.GetCurrentAction().name)
+	}
+
+	if err := runner.Tick(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if runner.GetCurrentAction() != nil {
+		t.Errorf("Expected plan to be completed, got '%s'", runner.GetCurrentAction().name)
+	}
+
+	if getWood.started != 1 || makeFire.started != 1 {
+		t.Errorf("Expected each executor to start once, got %d and %d", getWood.started, makeFire.started)
+	}
+	if getWood.aborted != 0 || makeFire.aborted != 0 {
+		t.Error("Expected no executor to be aborted")
+	}
+}
+
+func TestRunner_Tick_ReplanOnFailure(t *testing.T) {
+	agent, getWood, _ := createFireAgent()
+	getWood.ticks = 0
+	getWood.status = FAILURE
+	runner := CreateRunner(agent, PlanOptions{MaxDepth: 10})
+
+	if err := runner.Tick(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if getWood.started != 1 {
+		t.Fatalf("Expected get_wood to be started once, got %d", getWood.started)
+	}
+	if getWood.aborted != 0 {
+		t.Error("Expected a failed action not to be aborted")
+	}
+
+	// A new plan was requested, and starts again with get_wood
+	if runner.GetCurrentAction() == nil || runner.GetCurrentAction().name != "get_wood" {
+		t.Fatal("Expected the new plan to start with 'get_wood'")
+	}
+	if err := runner.Tick(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if getWood.started != 2 {
+		t.Errorf("Expected get_wood to be started twice, got %d", getWood.started)
+	}
+}
+
+func TestRunner_Tick_ReplanOnInvalidConditions(t *testing.T) {
+	agent, getWood, makeFire := createFireAgent()
+	getWood.ticks = 0
+	runner := CreateRunner(agent, PlanOptions{MaxDepth: 10})
+
+	if err := runner.Tick(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if runner.GetCurrentAction().name != "make_fire" {
+		t.Fatalf("Expected current action 'make_fire', got '%s'", runner.GetCurrentAction().name)
+	}
+
+	// The wood was stolen before make_fire started
+	State[bool]{Key: 1, Value: false}.Store(&agent.w)
+
+	if err := runner.Tick(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if makeFire.started != 0 {
+		t.Error("Expected make_fire not to start without wood")
+	}
+	if runner.GetCurrentAction() == nil || runner.GetCurrentAction().name != "get_wood" {
+		t.Error("Expected the new plan to start with 'get_wood'")
+	}
+}
+
+func TestRunner_Tick_HigherPriorityGoal(t *testing.T) {
+	agent, getWood, _ := createFireAgent()
+	agent.actions.AddAction("flee", 1.0, false, Conditions{}, Effects{
+		EffectBool{Key: 3, Value: true, Operator: SET},
+	})
+	agent.goals["survive"] = goalInterface{
+		Conditions: Conditions{
+			&ConditionBool{Key: 3, Value: true, Operator: EQUAL},
+		},
+		PriorityFn: func(sensors Sensors) float32 {
+			if sensors.GetSensor("danger") == true {
+				return 2.0
+			}
+			return 0.0
+		},
+	}
+	SetState[bool](agent, 3, false)
+	runner := CreateRunner(agent, PlanOptions{MaxDepth: 10})
+
+	if err := runner.Tick(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if runner.GetGoalName() != "stay_warm" {
+		t.Fatalf("Expected goal 'stay_warm', got '%s'", runner.GetGoalName())
+	}
+
+	SetSensor(agent, "danger", true)
+	if err := runner.Tick(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if getWood.aborted != 1 {
+		t.Errorf("Expected get_wood to be aborted once, got %d", getWood.aborted)
+	}
+	if runner.GetGoalName() != "survive" {
+		t.Errorf("Expected goal 'survive', got '%s'", runner.GetGoalName())
+	}
+}
+
+func TestRunner_Tick_Fallback(t *testing.T) {
+	agent, getWood, makeFire := createFireAgent()
+	agent.actions.AddAction("flee", 1.0, false, Conditions{
+		&ConditionBool{Key: 4, Value: true, Operator: EQUAL},
+	}, Effects{
+		EffectBool{Key: 3, Value: true, Operator: SET},
+	})
+	agent.goals["survive"] = goalInterface{
+		Conditions: Conditions{
+			&ConditionBool{Key: 3, Value: true, Operator: EQUAL},
+		},
+		PriorityFn: func(sensors Sensors) float32 {
+			return 2.0
+		},
+	}
+	SetState[bool](agent, 3, false)
+	SetState[bool](agent, 4, false)
+	runner := CreateRunner(agent, PlanOptions{MaxDepth: 10, Fallback: true})
+
+	// survive is not achievable: the plan of stay_warm is not dropped on each tick
+	for i := 0; i < 3; i++ {
+		if err := runner.Tick(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if runner.GetGoalName() != "stay_warm" {
+		t.Fatalf("Expected goal 'stay_warm', got '%s'", runner.GetGoalName())
+	}
+	if getWood.started != 1 || getWood.aborted != 0 {
+		t.Errorf("Expected get_wood to be started once and never aborted, got %d and %d", getWood.started, getWood.aborted)
+	}
+	if runner.GetCurrentAction() == nil || runner.GetCurrentAction().name != "make_fire" {
+		t.Fatal("Expected the plan to progress to 'make_fire'")
+	}
+
+	// survive becomes achievable
+	SetState[bool](agent, 4, true)
+	if err := runner.Tick(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if runner.GetGoalName() != "survive" {
+		t.Errorf("Expected goal 'survive', got '%s'", runner.GetGoalName())
+	}
+	if makeFire.started != 0 {
+		t.Error("Expected make_fire not to start")
+	}
+}
+
+func TestRunner_Tick_Utility(t *testing.T) {
+	agent, getWood, _ := createFireAgent()
+	agent.actions.AddAction("flee", 10.0, false, Conditions{}, Effects{
+		EffectBool{Key: 3, Value: true, Operator: SET},
+	})
+	agent.goals["survive"] = goalInterface{
+		Conditions: Conditions{
+			&ConditionBool{Key: 3, Value: true, Operator: EQUAL},
+		},
+		PriorityFn: func(sensors Sensors) float32 {
+			return 2.0
+		},
+	}
+	agent.goals["rest"] = goalInterface{
+		Conditions: Conditions{
+			&ConditionBool{Key: 3, Value: true, Operator: EQUAL},
+		},
+		PriorityFn: func(sensors Sensors) float32 {
+			if sensors.GetSensor("tired") == true {
+				return 1.0
+			}
+			return 0.0
+		},
+	}
+	SetState[bool](agent, 3, false)
+	runner := CreateRunner(agent, PlanOptions{MaxDepth: 10, UtilityFn: UtilityPriorityPerCost})
+
+	// stay_warm has the best utility, though survive has the highest priority
+	for i := 0; i < 2; i++ {
+		if err := runner.Tick(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if runner.GetGoalName() != "stay_warm" {
+		t.Fatalf("Expected goal 'stay_warm', got '%s'", runner.GetGoalName())
+	}
+	if getWood.started != 1 || getWood.aborted != 0 {
+		t.Errorf("Expected get_wood to be started once and never aborted, got %d and %d", getWood.started, getWood.aborted)
+	}
+
+	// A new candidate goal drops the plan
+	SetSensor(agent, "tired", true)
+	if err := runner.Tick(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if getWood.aborted != 1 || getWood.started != 2 {
+		t.Errorf("Expected get_wood to be aborted and started again, got %d and %d", getWood.aborted, getWood.started)
+	}
+}
+
+func TestRunner_Tick_NoGoal(t *testing.T) {
+	agent := CreateAgent(Goals{}, Actions{})
+	runner := CreateRunner(&agent, PlanOptions{MaxDepth: 10})
+
+	if err := runner.Tick(); !errors.Is(err, ErrNoGoalAvailable) {
+		t.Errorf("Expected ErrNoGoalAvailable, got %v", err)
+	}
+	if runner.GetCurrentAction() != nil {
+		t.Error("Expected no current action")
+	}
+}
+
+func TestRunner_Tick_PartialPlan(t *testing.T) {
+	agent := createBudgetAgent()
+
+	runner := CreateRunner(&agent, PlanOptions{MaxDepth: 2000, MaxNodes: 10})
+	if err := runner.Tick(); !errors.Is(err, ErrNodeBudget) {
+		t.Fatalf("Expected ErrNodeBudget, got %v", err)
+	}
+	if runner.GetCurrentAction() != nil {
+		t.Error("Expected no plan without PartialPlan")
+	}
+
+	runner = CreateRunner(&agent, PlanOptions{MaxDepth: 2000, MaxNodes: 10, PartialPlan: true})
+	if err := runner.Tick(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if runner.GetCurrentAction() == nil {
+		t.Error("Expected the partial plan to be executed")
+	}
+}
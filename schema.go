@@ -0,0 +1,443 @@
+package goapai
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"slices"
+	"sync/atomic"
+)
+
+type stateKind uint8
+
+const (
+	kindNone stateKind = iota
+	kindInt8
+	kindInt
+	kindUint8
+	kindUint64
+	kindFloat64
+	kindBool
+	kindString
+)
+
+var stateKindNames = [...]string{"none", "int8", "int", "uint8", "uint64", "float64", "bool", "string"}
+
+func (kind stateKind) String() string {
+	return stateKindNames[kind]
+}
+
+// kindOf returns the stateKind of T, or kindNone for the types defined from a supported type.
+func kindOf[T Numeric | bool | string]() stateKind {
+	var zero T
+
+	switch any(zero).(type) {
+	case int8:
+		return kindInt8
+	case int:
+		return kindInt
+	case uint8:
+		return kindUint8
+	case uint64:
+		return kindUint64
+	case float64:
+		return kindFloat64
+	case bool:
+		return kindBool
+	case string:
+		return kindString
+	}
+
+	return kindNone
+}
+
+// Schema declares the type of the states stored in a dense layout.
+//
+// By default, the world state is a list of boxed State values: each lookup scans the list,
+// and each modified state allocates a new interface value during planning. The states
+// registered in a Schema are instead stored in a flat array indexed by their StateKey,
+// along with a presence bitset: lookups are O(1), and cloning a world during planning
+// is a single copy of this array.
+//
+// A Schema must be complete before creating the agents using it, and can be shared by
+// several agents: once an agent uses it, registering a new state returns an error.
+// The states not registered are still stored in the default layout.
+//
+// Example:
+//
+//	schema := CreateSchema()
+//	RegisterState[int](schema, ATTRIBUTE_HEALTH)
+//	RegisterState[bool](schema, ATTRIBUTE_HUNGRY)
+//	agent, err := CreateAgentWithSchema(goals, actions, schema)
+type Schema struct {
+	slotByKey  []int32 // Slot of each StateKey, -1 if not registered
+	keys       []StateKey
+	kinds      []stateKind
+	names      []string // Name of each slot, empty if the state is not named
+	slotByName map[string]int
+	hasStrings bool
+	inUse      atomic.Bool // Set once an agent stores its states in the layout of the schema
+}
+
+// CreateSchema creates an empty Schema.
+func CreateSchema() *Schema {
+	return &Schema{}
+}
+
+// RegisterState declares the state key with the type T in the schema.
+//
+// It returns an error if the key is already registered with another type, if T is a type defined
+// from a supported type (e.g. type Health int), that cannot be stored densely, or if the key is not
+// registered yet and an agent already uses the schema.
+func RegisterState[T Numeric | bool | string](schema *Schema, key StateKey) error {
+	kind := kindOf[T]()
+	if kind == kindNone {
+		var zero T
+		return fmt.Errorf("%w: type %T of state %d cannot be registered", ErrTypeMismatch, zero, key)
+	}
+
+	if slot, ok := schema.slot(key); ok {
+		if schema.kinds[slot] != kind {
+			return fmt.Errorf("%w: state %d is already registered with another type", ErrTypeMismatch, key)
+		}
+		return nil
+	}
+	if schema.inUse.Load() {
+		return fmt.Errorf("%w: state %d registered after an agent was created with the schema", ErrSchemaViolation, key)
+	}
+
+	for int(key) >= len(schema.slotByKey) {
+		schema.slotByKey = append(schema.slotByKey, -1)
+	}
+	schema.slotByKey[key] = int32(len(schema.keys))
+	schema.keys = append(schema.keys, key)
+	schema.kinds = append(schema.kinds, kind)
+	schema.names = append(schema.names, "")
+	schema.hasStrings = schema.hasStrings || kind == kindString
+
+	return nil
+}
+
+func (schema *Schema) slot(key StateKey) (int, bool) {
+	if schema == nil || int(key) >= len(schema.slotByKey) || schema.slotByKey[key] < 0 {
+		return 0, false
+	}
+
+	return int(schema.slotByKey[key]), true
+}
+
+// CreateAgentWithSchema creates a new Agent, storing the states registered in schema in a dense layout.
+// With a nil schema, the agent is created as with CreateAgent. It returns an error matching ErrSchemaViolation if the goals or the actions do not use the states
+// with their registered type (see Schema.Validate).
+func CreateAgentWithSchema(goals Goals, actions Actions, schema *Schema) (Agent, error) {
+	if err := schema.Validate(goals, actions); err != nil {
+		return Agent{}, err
+	}
+
+	return createAgentWithSchema(goals, actions, schema), nil
+}
+
+func createAgentWithSchema(goals Goals, actions Actions, schema *Schema) Agent {
+	agent := CreateAgent(goals, actions)
+	agent.w.dense = createDenseStates(schema)
+
+	return agent
+}
+
+// denseStates stores the values of the states registered in a Schema.
+type denseStates struct {
+	schema  *Schema
+	slots   []uint64 // Presence bitset, followed by the value of each slot
+	strings []string // Values of the string slots, nil if the schema has none
+}
+
+func createDenseStates(schema *Schema) *denseStates {
+	if schema == nil {
+		return nil
+	}
+	schema.inUse.Store(true)
+
+	dense := &denseStates{
+		schema: schema,
+		slots:  make([]uint64, presenceWords(len(schema.keys))+len(schema.keys)),
+	}
+	if schema.hasStrings {
+		dense.strings = make([]string, len(schema.keys))
+	}
+
+	return dense
+}
+
+func presenceWords(slots int) int {
+	return (slots + 63) / 64
+}
+
+// slot returns the slot of key, and false if key is not registered or dense is nil.
+func (dense *denseStates) slot(key StateKey) (int, bool) {
+	if dense == nil {
+		return 0, false
+	}
+
+	return dense.schema.slot(key)
+}
+
+func (dense denseStates) has(slot int) bool {
+	return dense.slots[slot/64]&(1<<(slot%64)) != 0
+}
+
+func (dense denseStates) value(slot int) uint64 {
+	return dense.slots[presenceWords(len(dense.schema.keys))+slot]
+}
+
+func (dense denseStates) set(slot int, value uint64, str string) {
+	dense.slots[slot/64] |= 1 << (slot % 64)
+	dense.slots[presenceWords(len(dense.schema.keys))+slot] = value
+	if dense.strings != nil {
+		dense.strings[slot] = str
+	}
+}
+
+func (dense denseStates) unset(slot int) {
+	dense.slots[slot/64] &^= 1 << (slot % 64)
+	dense.slots[presenceWords(len(dense.schema.keys))+slot] = 0
+	if dense.strings != nil {
+		dense.strings[slot] = ""
+	}
+}
+
+// state returns the boxed State of a present slot.
+func (dense denseStates) state(slot int) StateInterface {
+	key := dense.schema.keys[slot]
+	bits := dense.value(slot)
+
+	switch dense.schema.kinds[slot] {
+	case kindInt8:
+		return createHashedState(key, fromSlot[int8](bits, ""))
+	case kindInt:
+		return createHashedState(key, fromSlot[int](bits, ""))
+	case kindUint8:
+		return createHashedState(key, fromSlot[uint8](bits, ""))
+	case kindUint64:
+		return createHashedState(key, fromSlot[uint64](bits, ""))
+	case kindFloat64:
+		return createHashedState(key, fromSlot[float64](bits, ""))
+	case kindBool:
+		return createHashedState(key, fromSlot[bool](bits, ""))
+	default:
+		return createHashedState(key, dense.strings[slot])
+	}
+}
+
+// forEach calls fn with the slot of each present state.
+func (dense denseStates) forEach(fn func(slot int)) {
+	for word := 0; word < presenceWords(len(dense.schema.keys)); word++ {
+		for mask := dense.slots[word]; mask != 0; mask &= mask - 1 {
+			fn(word*64 + bits.TrailingZeros64(mask))
+		}
+	}
+}
+
+func createHashedState[T Numeric | bool | string](key StateKey, value T) State[T] {
+	state := State[T]{Key: key, Value: value}
+	state.hash = state.Hash()
+
+	return state
+}
+
+// toSlot converts a value to its dense representation.
+func toSlot[T Numeric | bool | string](value T) (uint64, string) {
+	switch v := any(value).(type) {
+	case int8:
+		return uint64(int64(v)), ""
+	case int:
+		return uint64(int64(v)), ""
+	case uint8:
+		return uint64(v), ""
+	case uint64:
+		return v, ""
+	case float64:
+		return math.Float64bits(v), ""
+	case bool:
+		if v {
+			return 1, ""
+		}
+		return 0, ""
+	case string:
+		return 0, v
+	}
+
+	return 0, ""
+}
+
+// fromSlot converts a dense representation back to its value.
+func fromSlot[T Numeric | bool | string](bits uint64, str string) T {
+	var value T
+
+	switch p := any(&value).(type) {
+	case *int8:
+		*p = int8(int64(bits))
+	case *int:
+		*p = int(int64(bits))
+	case *uint8:
+		*p = uint8(bits)
+	case *uint64:
+		*p = bits
+	case *float64:
+		*p = math.Float64frombits(bits)
+	case *bool:
+		*p = bits != 0
+	case *string:
+		*p = str
+	}
+
+	return value
+}
+
+// lookupState returns the value of the state key in w.
+// The boolean is false if the state does not exist, or if its type is not T.
+func lookupState[T Numeric | bool | string](w *world, key StateKey) (T, bool) {
+	if slot, ok := w.dense.slot(key); ok {
+		return lookupDenseState[T](w.dense, slot)
+	}
+
+	k := w.states.GetIndex(key)
+	if k < 0 {
+		var zero T
+		return zero, false
+	}
+	state, ok := w.states[k].(State[T])
+
+	return state.Value, ok
+}
+
+// lookupDenseState returns the value of a slot of dense.
+// The boolean is false if the slot is not set, or if its type is not T.
+func lookupDenseState[T Numeric | bool | string](dense *denseStates, slot int) (T, bool) {
+	if !dense.has(slot) || dense.schema.kinds[slot] != kindOf[T]() {
+		var zero T
+		return zero, false
+	}
+
+	var str string
+	if dense.strings != nil {
+		str = dense.strings[slot]
+	}
+
+	return fromSlot[T](dense.value(slot), str), true
+}
+
+// storeState inserts or replaces the state key in w, and updates the world's hash.
+// It returns an error if the key is registered in the schema with another type.
+func storeState[T Numeric | bool | string](w *world, key StateKey, value T) error {
+	state := createHashedState(key, value)
+
+	slot, ok := w.dense.slot(key)
+	if !ok {
+		var oldHash uint64
+		k := w.states.GetIndex(key)
+		if k < 0 {
+			w.states = append(w.states, state)
+		} else {
+			oldHash = w.states[k].GetHash()
+			w.states[k] = state
+		}
+		w.hash = updateHashIncremental(w.hash, oldHash, state.hash)
+
+		return nil
+	}
+
+	if w.dense.schema.kinds[slot] != kindOf[T]() {
+		return fmt.Errorf("%w: state %s", ErrTypeMismatch, w.dense.schema.FormatKey(key))
+	}
+
+	var oldHash uint64
+	if oldValue, ok := lookupDenseState[T](w.dense, slot); ok {
+		oldHash = State[T]{Key: key, Value: oldValue}.Hash()
+	}
+	bits, str := toSlot(value)
+	w.dense.set(slot, bits, str)
+	w.hash = updateHashIncremental(w.hash, oldHash, state.hash)
+
+	return nil
+}
+
+// hasState returns true if the state key exists in w, whatever its type.
+func (w *world) hasState(key StateKey) bool {
+	if slot, ok := w.dense.slot(key); ok {
+		return w.dense.has(slot)
+	}
+
+	return w.states.GetIndex(key) >= 0
+}
+
+// getState returns the boxed state key of w.
+func (w *world) getState(key StateKey) (StateInterface, bool) {
+	if slot, ok := w.dense.slot(key); ok {
+		if !w.dense.has(slot) {
+			return nil, false
+		}
+		return w.dense.state(slot), true
+	}
+
+	k := w.states.GetIndex(key)
+	if k < 0 {
+		return nil, false
+	}
+
+	return w.states[k], true
+}
+
+// deleteState removes the state key from w, and updates the world's hash.
+func (w *world) deleteState(key StateKey) bool {
+	state, ok := w.getState(key)
+	if !ok {
+		return false
+	}
+
+	w.hash = updateHashIncremental(w.hash, state.GetHash(), 0)
+	if slot, ok := w.dense.slot(key); ok {
+		w.dense.unset(slot)
+	} else {
+		k := w.states.GetIndex(key)
+		w.states = slices.Delete(w.states, k, k+1)
+	}
+
+	return true
+}
+
+// schema returns the Schema of the states of w, nil if its agent was created without schema.
+func (w *world) schema() *Schema {
+	if w.dense == nil {
+		return nil
+	}
+
+	return w.dense.schema
+}
+
+// getKeys returns the keys of all the states in w.
+func (w *world) getKeys() []StateKey {
+	keys := make([]StateKey, 0, len(w.states))
+	for _, state := range w.states {
+		keys = append(keys, state.GetKey())
+	}
+	if w.dense != nil {
+		w.dense.forEach(func(slot int) {
+			keys = append(keys, w.dense.schema.keys[slot])
+		})
+	}
+
+	return keys
+}
+
+// clone returns a copy of w that can be modified without altering w.
+func (w world) clone() world {
+	w.states = slices.Clone(w.states)
+	if w.dense != nil {
+		dense := *w.dense
+		dense.slots = slices.Clone(dense.slots)
+		dense.strings = slices.Clone(dense.strings)
+		w.dense = &dense
+	}
+
+	return w
+}
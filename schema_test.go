@@ -0,0 +1,353 @@
+package goapai
+
+import (
+	"errors"
+	"testing"
+)
+
+func createTestSchema(t *testing.T) *Schema {
+	schema := CreateSchema()
+	if err := RegisterState[int](schema, 1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := RegisterState[bool](schema, 2); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := RegisterState[string](schema, 3); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := RegisterState[float64](schema, 100); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	return schema
+}
+
+func TestRegisterState(t *testing.T) {
+	type Health int
+
+	schema := CreateSchema()
+
+	if err := RegisterState[int](schema, 5); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := RegisterState[int](schema, 5); err != nil {
+		t.Errorf("Expected registering the same type twice to succeed, got %v", err)
+	}
+	if err := RegisterState[bool](schema, 5); !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("Expected ErrTypeMismatch, got %v", err)
+	}
+	if err := RegisterState[Health](schema, 6); !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("Expected ErrTypeMismatch for a defined type, got %v", err)
+	}
+
+	if slot, ok := schema.slot(5); !ok || slot != 0 {
+		t.Errorf("Expected key 5 in slot 0, got %d, %v", slot, ok)
+	}
+	if _, ok := schema.slot(4); ok {
+		t.Error("Expected key 4 not to be registered")
+	}
+}
+
+func TestSlot_RoundTrip(t *testing.T) {
+	if v := fromSlot[int](toSlot(-42)); v != -42 {
+		t.Errorf("int round trip = %d", v)
+	}
+	if v := fromSlot[int8](toSlot(int8(-8))); v != -8 {
+		t.Errorf("int8 round trip = %d", v)
+	}
+	if v := fromSlot[uint64](toSlot(uint64(1 << 63))); v != 1<<63 {
+		t.Errorf("uint64 round trip = %d", v)
+	}
+	if v := fromSlot[float64](toSlot(3.14)); v != 3.14 {
+		t.Errorf("float64 round trip = %f", v)
+	}
+	if v := fromSlot[bool](toSlot(true)); !v {
+		t.Error("bool round trip = false")
+	}
+	if v := fromSlot[string](toSlot("test")); v != "test" {
+		t.Errorf("string round trip = %s", v)
+	}
+}
+
+func TestCreateAgentWithSchema_States(t *testing.T) {
+	agent, err := CreateAgentWithSchema(Goals{}, Actions{}, createTestSchema(t))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	SetState[int](&agent, 1, 42)
+	SetState[bool](&agent, 2, true)
+	SetState[string](&agent, 3, "test")
+	SetState[int](&agent, 4, 7) // Not registered: stored in the default layout
+
+	if len(agent.w.states) != 1 {
+		t.Errorf("Expected 1 state in the default layout, got %d", len(agent.w.states))
+	}
+
+	if value, ok := GetState[int](&agent, 1); !ok || value != 42 {
+		t.Errorf("GetState() = %v, %v, want 42, true", value, ok)
+	}
+	if value, ok := GetState[string](&agent, 3); !ok || value != "test" {
+		t.Errorf("GetState() = %v, %v, want 'test', true", value, ok)
+	}
+	if value, ok := GetState[int](&agent, 4); !ok || value != 7 {
+		t.Errorf("GetState() = %v, %v, want 7, true", value, ok)
+	}
+	if _, ok := GetState[bool](&agent, 1); ok {
+		t.Error("Expected GetState with the wrong type to fail")
+	}
+	if agent.HasState(100) {
+		t.Error("Expected registered state 100 not to be set")
+	}
+
+	// A registered key cannot change its type
+	SetState[bool](&agent, 1, true)
+	if value, ok := GetState[int](&agent, 1); !ok || value != 42 {
+		t.Errorf("Expected state 1 to keep its int value, got %v, %v", value, ok)
+	}
+	if err := TrySetState[bool](&agent, 1, true); !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("Expected ErrTypeMismatch, got %v", err)
+	}
+	if err := TrySetState[int](&agent, 1, 43); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	err = agent.TrySetStates(State[int]{Key: 1, Value: 42}, State[string]{Key: 2, Value: "true"}, State[bool]{Key: 4, Value: true})
+	if !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("Expected ErrTypeMismatch, got %v", err)
+	}
+	if value, _ := GetState[int](&agent, 1); value != 42 {
+		t.Errorf("Expected the valid states to be set, got %d", value)
+	}
+	SetState[int](&agent, 4, 7)
+
+	keys := agent.GetStateKeys()
+	if len(keys) != 4 {
+		t.Errorf("Expected 4 keys, got %v", keys)
+	}
+	if value, ok := agent.GetStateValue(2); !ok || value != true {
+		t.Errorf("GetStateValue() = %v, %v, want true, true", value, ok)
+	}
+
+	if !agent.DeleteState(2) || agent.HasState(2) {
+		t.Error("Expected state 2 to be deleted")
+	}
+}
+
+func TestCreateAgentWithSchema_Violation(t *testing.T) {
+	tests := []struct {
+		name    string
+		goals   Goals
+		actions Actions
+		err     error
+	}{
+		{
+			name:  "valid",
+			goals: Goals{"goal": {Conditions: Conditions{&Condition[int]{Key: 1, Value: 10, Operator: UPPER_OR_EQUAL}}}},
+		},
+		{
+			name:  "goal condition",
+			goals: Goals{"goal": {Conditions: Conditions{&ConditionBool{Key: 1, Value: true, Operator: EQUAL}}}},
+			err:   ErrSchemaViolation,
+		},
+		{
+			name:    "action effect",
+			actions: Actions{&Action{name: "light", effects: Effects{EffectString{Key: 2, Value: "on", Operator: SET}}}},
+			err:     ErrSchemaViolation,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := CreateAgentWithSchema(test.goals, test.actions, createTestSchema(t))
+			if !errors.Is(err, test.err) {
+				t.Errorf("Expected error %v, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestCreateAgentWithSchema_NilSchema(t *testing.T) {
+	agent, err := CreateAgentWithSchema(Goals{}, Actions{}, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	SetState[int](&agent, 1, 42)
+	if value, ok := GetState[int](&agent, 1); !ok || value != 42 {
+		t.Errorf("GetState() = %v, %v, want 42, true", value, ok)
+	}
+}
+
+func TestRegisterState_SchemaInUse(t *testing.T) {
+	schema := createTestSchema(t)
+	if err := RegisterNamedState[bool](schema, 2, "fire"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := CreateAgentWithSchema(Goals{}, Actions{}, schema); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		register func() error
+		err      error
+	}{
+		{"registered state", func() error { return RegisterState[int](schema, 1) }, nil},
+		{"registered name", func() error { return RegisterNamedState[bool](schema, 2, "fire") }, nil},
+		{"new state", func() error { return RegisterState[int](schema, 5) }, ErrSchemaViolation},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.register(); !errors.Is(err, test.err) {
+				t.Errorf("Expected error %v, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestCreateAgentWithSchema_Hash(t *testing.T) {
+	dense, err := CreateAgentWithSchema(Goals{}, Actions{}, createTestSchema(t))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	boxed := CreateAgent(Goals{}, Actions{})
+
+	for _, agent := range []*Agent{&dense, &boxed} {
+		SetState[int](agent, 1, 42)
+		SetState[bool](agent, 2, true)
+		SetState[string](agent, 3, "test")
+		SetState[int](agent, 1, 50)
+	}
+
+	if dense.w.hash != boxed.w.hash {
+		t.Errorf("Expected the same hash for both layouts, got %d and %d", dense.w.hash, boxed.w.hash)
+	}
+
+	dense.DeleteState(3)
+	boxed.DeleteState(3)
+	if dense.w.hash != boxed.w.hash {
+		t.Errorf("Expected the same hash for both layouts after delete, got %d and %d", dense.w.hash, boxed.w.hash)
+	}
+}
+
+func TestCreateAgentWithSchema_Effects(t *testing.T) {
+	agent, err := CreateAgentWithSchema(Goals{}, Actions{}, createTestSchema(t))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	SetState[int](&agent, 1, 10)
+
+	effects := Effects{
+		Effect[int]{Key: 1, Value: 5, Operator: ADD},
+		EffectBool{Key: 2, Value: true, Operator: SET},
+		EffectString{Key: 3, Value: "fire", Operator: SET},
+	}
+	if err := effects.apply(&agent.w); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if value, _ := GetState[int](&agent, 1); value != 15 {
+		t.Errorf("Expected value 15, got %d", value)
+	}
+	if effects.satisfyStates(agent.w) {
+		t.Error("Expected ADD effect not to be satisfied")
+	}
+	if !(EffectBool{Key: 2, Value: true, Operator: SET}).check(agent.w) {
+		t.Error("Expected bool effect to be satisfied")
+	}
+
+	// The schema declares state 2 as bool
+	err = Effect[int]{Key: 2, Value: 1, Operator: SET}.apply(&agent.w)
+	if !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("Expected ErrTypeMismatch, got %v", err)
+	}
+	err = Effect[float64]{Key: 100, Value: 1, Operator: SET}.apply(&agent.w)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestCreateAgentWithSchema_Plan(t *testing.T) {
+	actions := Actions{}
+	actions.AddAction("get_wood", 2.0, false, Conditions{}, Effects{
+		EffectBool{Key: 2, Value: true, Operator: SET},
+	})
+	actions.AddAction("chop", 1.0, true, Conditions{
+		&ConditionBool{Key: 2, Value: true, Operator: EQUAL},
+	}, Effects{
+		Effect[int]{Key: 1, Value: 10, Operator: ADD},
+	})
+
+	goals := Goals{
+		"wood": {
+			Conditions: Conditions{
+				&Condition[int]{Key: 1, Value: 30, Operator: UPPER_OR_EQUAL},
+			},
+			PriorityFn: func(sensors Sensors) float32 {
+				return 1.0
+			},
+		},
+	}
+
+	dense, err := CreateAgentWithSchema(goals, actions, createTestSchema(t))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	boxed := CreateAgent(goals, actions)
+	for _, agent := range []*Agent{&dense, &boxed} {
+		SetState[int](agent, 1, 0)
+		SetState[bool](agent, 2, false)
+	}
+
+	denseResult, err := FindPlan(dense, PlanOptions{MaxDepth: 10})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	boxedResult, err := FindPlan(boxed, PlanOptions{MaxDepth: 10})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(denseResult.Plan) != 5 || len(denseResult.Plan) != len(boxedResult.Plan) {
+		t.Fatalf("Expected plans with 5 actions, got %d and %d", len(denseResult.Plan), len(boxedResult.Plan))
+	}
+	for i := range denseResult.Plan {
+		if denseResult.Plan[i].name != boxedResult.Plan[i].name {
+			t.Errorf("Expected action %d to be '%s', got '%s'", i, boxedResult.Plan[i].name, denseResult.Plan[i].name)
+		}
+	}
+	if denseResult.NodesGenerated != boxedResult.NodesGenerated {
+		t.Errorf("Expected the same search, got %d and %d generated nodes", denseResult.NodesGenerated, boxedResult.NodesGenerated)
+	}
+
+	backward, err := FindPlan(dense, PlanOptions{MaxDepth: 10, Direction: BACKWARD})
+	if err != nil || len(backward.Plan) != 5 {
+		t.Errorf("Expected backward plan with 5 actions, got %d (%v)", len(backward.Plan), err)
+	}
+}
+
+func TestSimulateActionState_DenseAllocations(t *testing.T) {
+	agent, err := CreateAgentWithSchema(Goals{}, Actions{}, createTestSchema(t))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	SetState[int](&agent, 1, 0)
+	SetState[bool](&agent, 2, false)
+
+	action := &Action{
+		effects: Effects{
+			Effect[int]{Key: 1, Value: 10, Operator: ADD},
+			EffectBool{Key: 2, Value: true, Operator: SET},
+		},
+	}
+
+	// Cloning the dense states with their slots and strings, and the world escaping to the effects.
+	// Modifying a state does not allocate, whatever the number of effects.
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _, _ = simulateActionState(action, agent.w)
+	})
+	if allocs > 4 {
+		t.Errorf("Expected at most 4 allocations, got %v", allocs)
+	}
+}
@@ -0,0 +1,88 @@
+package goapai
+
+import (
+	"fmt"
+	"slices"
+)
+
+// PlanStepError describes the first step of a plan that cannot be performed, see Plan.Validate.
+// It matches ErrInvalidPlan with errors.Is, as well as the error of the effects if they could not be applied.
+type PlanStepError struct {
+	Step      int    // Index of the action in the plan
+	Action    string // Name of the action
+	Condition string // Readable form of the first condition that does not hold, e.g. "wood >= 5"
+	Err       error  // Error of the effects, nil if a condition does not hold
+}
+
+func (err *PlanStepError) Error() string {
+	if err.Err != nil {
+		return fmt.Sprintf("%v: step %d %q: %v", ErrInvalidPlan, err.Step, err.Action, err.Err)
+	}
+
+	return fmt.Sprintf("%v: step %d %q: condition %s does not hold", ErrInvalidPlan, err.Step, err.Action, err.Condition)
+}
+
+func (err *PlanStepError) Unwrap() []error {
+	if err.Err != nil {
+		return []error{ErrInvalidPlan, err.Err}
+	}
+
+	return []error{ErrInvalidPlan}
+}
+
+// Validate checks that the plan can still be performed from the agent's current world state.
+//
+// The actions are replayed in order: the conditions of each one must hold in the world state
+// predicted by the previous ones, and its effects must apply. It returns nil if the whole plan is valid,
+// or a *PlanStepError for the first step that is not. The plan can be a remaining part of a plan
+// being executed, starting with its current action.
+//
+// Example:
+//
+//	var stepErr *goapai.PlanStepError
+//	if err := plan.Validate(&agent); errors.As(err, &stepErr) {
+//	    fmt.Println("replan from step", stepErr.Step, "as", stepErr.Condition, "does not hold")
+//	}
+func (plan Plan) Validate(agent *Agent) error {
+	_, err := plan.replay(agent.planningWorld(), nil)
+
+	return err
+}
+
+// Simulate returns the world state predicted after each step of the plan, performed from the agent's
+// current world state: the view at index i is the world state once the action i is performed.
+// If a step cannot be performed, the views of the previous steps are returned with a *PlanStepError,
+// as with Validate.
+func (plan Plan) Simulate(agent *Agent) ([]WorldView, error) {
+	views := make([]WorldView, 0, len(plan))
+	_, err := plan.replay(agent.planningWorld(), func(w world) {
+		views = append(views, WorldView{w: w.clone()})
+	})
+
+	return views, err
+}
+
+// replay applies each action of the plan in order to w, checking their conditions first, and calls
+// stepFn with the world state after each step if not nil. It returns the resulting world state, or
+// the world state where the first step that could not be performed failed, and its PlanStepError.
+// The states of w are modified in place: callers pass a clone of a world they keep.
+func (plan Plan) replay(w world, stepFn func(w world)) (world, error) {
+	for i, action := range plan {
+		if index := slices.IndexFunc(action.conditions, func(condition ConditionInterface) bool { return !condition.Check(w) }); index >= 0 {
+			return w, &PlanStepError{
+				Step:      i,
+				Action:    action.name,
+				Condition: describeCondition(action.conditions[index], w.schema()),
+			}
+		}
+
+		if err := action.effects.apply(&w); err != nil {
+			return w, &PlanStepError{Step: i, Action: action.name, Err: err}
+		}
+		if stepFn != nil {
+			stepFn(w)
+		}
+	}
+
+	return w, nil
+}
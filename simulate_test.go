@@ -0,0 +1,116 @@
+package goapai
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPlan_Validate(t *testing.T) {
+	agent := createTraceAgent(t)
+
+	result, err := FindPlan(agent, PlanOptions{MaxDepth: 5})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	plan := result.Plan
+	if names := strings.Join(planNames(plan), ","); names != "take_axe,chop_wood,chop_wood" {
+		t.Fatalf("Expected the plan take_axe,chop_wood,chop_wood, got %s", names)
+	}
+
+	if err := plan.Validate(&agent); err != nil {
+		t.Errorf("Expected the plan to be valid, got %v", err)
+	}
+	// The remaining steps are valid as long as the axe is in hand
+	if err := plan[2:].Validate(&agent); !errors.Is(err, ErrInvalidPlan) {
+		t.Errorf("Expected ErrInvalidPlan, got %v", err)
+	}
+	SetState[bool](&agent, 1, true)
+	if err := plan[2:].Validate(&agent); err != nil {
+		t.Errorf("Expected the remaining plan to be valid, got %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		plan      Plan
+		step      int
+		action    string
+		condition string
+		err       error
+	}{
+		{
+			name:      "condition failed",
+			plan:      Plan{&Action{}, plan[1], {name: "drop_axe", effects: Effects{EffectBool{Key: 1, Value: false, Operator: SET}}}, plan[2]},
+			step:      3,
+			action:    "chop_wood",
+			condition: "has_axe == true",
+		},
+		{
+			name:   "effect failed",
+			plan:   Plan{&Action{}, {name: "carve", effects: Effects{EffectString{Key: 2, Value: "spoon", Operator: SET}}}},
+			step:   1,
+			action: "carve",
+			err:    ErrTypeMismatch,
+		},
+	}
+
+	SetState[bool](&agent, 1, false)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.plan.Validate(&agent)
+
+			var stepErr *PlanStepError
+			if !errors.As(err, &stepErr) || !errors.Is(err, ErrInvalidPlan) {
+				t.Fatalf("Expected a PlanStepError, got %v", err)
+			}
+			if stepErr.Step != test.step || stepErr.Action != test.action || stepErr.Condition != test.condition {
+				t.Errorf("Expected step %d %s %q, got %d %s %q", test.step, test.action, test.condition, stepErr.Step, stepErr.Action, stepErr.Condition)
+			}
+			if test.err != nil && !errors.Is(err, test.err) {
+				t.Errorf("Expected %v, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestPlan_Simulate(t *testing.T) {
+	agent := createTraceAgent(t)
+
+	result, err := FindPlan(agent, PlanOptions{MaxDepth: 5})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	views, err := result.Plan.Simulate(&agent)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(views) != len(result.Plan) {
+		t.Fatalf("Expected %d views, got %d", len(result.Plan), len(views))
+	}
+
+	expected := []struct {
+		hasAxe bool
+		wood   int
+	}{{false, 0}, {true, 0}, {true, 1}, {true, 2}}
+	for i, view := range views {
+		hasAxe, _ := GetViewState[bool](view, 1)
+		wood, _ := GetViewState[int](view, 2)
+		if hasAxe != expected[i].hasAxe || wood != expected[i].wood {
+			t.Errorf("Expected has_axe %v and wood %d after step %d, got %v and %d", expected[i].hasAxe, expected[i].wood, i, hasAxe, wood)
+		}
+	}
+	if wood, _ := GetState[int](&agent, 2); wood != 0 {
+		t.Errorf("Expected the agent's world state to be unchanged, got wood %d", wood)
+	}
+
+	// The views of the steps performed are returned with the error
+	views, err = result.Plan[:1].Simulate(&agent)
+	if err != nil || len(views) != 1 {
+		t.Errorf("Expected 1 view, got %d, %v", len(views), err)
+	}
+	views, err = Plan{&Action{}, result.Plan[2]}.Simulate(&agent)
+	if !errors.Is(err, ErrInvalidPlan) || len(views) != 1 {
+		t.Errorf("Expected 1 view and ErrInvalidPlan, got %d, %v", len(views), err)
+	}
+}
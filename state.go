@@ -1,7 +1,9 @@
 package goapai
 
 import (
+	"fmt"
 	"math"
+	"slices"
 )
 
 type operator uint8
@@ -15,6 +17,16 @@ const (
 	UPPER
 )
 
+var operatorSymbols = [...]string{"==", "!=", "<=", "<", ">=", ">"}
+
+func (op operator) String() string {
+	if int(op) < len(operatorSymbols) {
+		return operatorSymbols[op]
+	}
+
+	return fmt.Sprintf("operator(%d)", uint8(op))
+}
+
 // Numeric is a constraint that defines the numeric types supported by generic State and Condition.
 // Supported types are: int8, int, uint8, uint64, and float64.
 type Numeric interface {
@@ -29,7 +41,7 @@ type StateInterface interface {
 	Check(w world, key StateKey) bool
 	GetKey() StateKey
 	GetValue() any
-	Store(w *world)
+	Store(w *world) error
 	GetHash() uint64
 	Hash() uint64
 	Distance(condition ConditionInterface) float32
@@ -52,9 +64,11 @@ type StateKey uint16
 type states []StateInterface
 
 type world struct {
-	Agent  *Agent
-	states states
-	hash   uint64
+	Agent        *Agent
+	states       states           // States not registered in the schema
+	dense        *denseStates     // States registered in the schema, nil if the agent has no schema
+	conditionFns conditionFnCache // Results of the ConditionFn, shared by the worlds of a planning request
+	hash         uint64
 }
 
 // Check compares world and states2 by their hash.
@@ -62,39 +76,48 @@ func (world world) Check(world2 world) bool {
 	return world.hash == world2.hash
 }
 
+// equal compares all the states of world and world2.
+// It is slower than Check, but safe against hash collisions.
+func (world world) equal(world2 world) bool {
+	if world.hash != world2.hash || len(world.states) != len(world2.states) {
+		return false
+	}
+	if (world.dense == nil) != (world2.dense == nil) {
+		return false
+	}
+	if world.dense != nil && (!slices.Equal(world.dense.slots, world2.dense.slots) || !slices.Equal(world.dense.strings, world2.dense.strings)) {
+		return false
+	}
+
+	for _, state := range world.states {
+		k := world2.states.GetIndex(state.GetKey())
+		if k < 0 || world2.states[k].GetValue() != state.GetValue() {
+			return false
+		}
+	}
+
+	return true
+}
+
 func (state State[T]) GetKey() StateKey {
 	return state.Key
 }
 
 func (state State[T]) Check(w world, key StateKey) bool {
-	k := w.states.GetIndex(key)
-	if k < 0 {
-		return false
-	}
-	s := w.states[k]
-	if agentState, ok := s.(State[T]); ok {
-		if agentState.Value == state.Value {
-			return true
-		}
-	}
+	value, ok := lookupState[T](&w, key)
 
-	return false
+	return ok && value == state.Value
 }
 
 func (state State[T]) GetValue() any {
 	return state.Value
 }
 
-func (state State[T]) Store(w *world) {
-	oldHash := state.hash
-	state.hash = state.Hash()
-	w.hash = updateHashIncremental(w.hash, oldHash, state.hash)
-	k := w.states.GetIndex(state.Key)
-	if k < 0 {
-		w.states = append(w.states, state)
-	} else {
-		w.states[k] = state
-	}
+// Store inserts or replaces the state with the same key in w, and updates the world's hash.
+// It returns an error matching ErrTypeMismatch, and ignores the state, if its key is registered in
+// the world's schema with another type.
+func (state State[T]) Store(w *world) error {
+	return storeState(w, state.Key, state.Value)
 }
 
 func (state State[T]) GetHash() uint64 {
@@ -136,6 +159,14 @@ func (state State[T]) Hash() uint64 {
 		}
 	}
 
+	// Finalize with an avalanche mix, so that the world hash (xor of all states' hashes)
+	// does not cancel out when several states share the same value
+	hash ^= hash >> 33
+	hash *= 0xff51afd7ed558ccd
+	hash ^= hash >> 33
+	hash *= 0xc4ceb9fe1a85ec53
+	hash ^= hash >> 33
+
 	return hash
 }
 
@@ -181,7 +212,13 @@ type ConditionInterface interface {
 // ConditionFn represents a procedural condition that evaluates against sensor data.
 //
 // Unlike state-based conditions, ConditionFn uses a custom function to check sensors.
-// The result is cached after the first evaluation to avoid redundant computation during planning.
+// During planning, the result is cached after the first evaluation to avoid redundant computation.
+// The cache belongs to the planning request: it is reset at each GetPlan, and the same ConditionFn
+// can be shared by several agents, and planned concurrently.
+//
+// When the condition also depends on the world state, set WorldFn instead of CheckFn: it is evaluated
+// against each world state simulated during planning, without cache. The backward search cannot
+// regress it: it returns an error matching errors.ErrUnsupported for the goals and actions using a WorldFn.
 //
 // Example:
 //
@@ -193,23 +230,107 @@ type ConditionInterface interface {
 //	    },
 //	}
 type ConditionFn struct {
-	Key      StateKey                 // Unique identifier for this condition
-	CheckFn  func(sensors Sensors) bool // Function that evaluates the condition
-	resolved bool                       // Whether the condition has been evaluated
-	valid    bool                       // Cached result of the evaluation
+	Key     StateKey                                   // Unique identifier for this condition
+	CheckFn func(sensors Sensors) bool                 // Function that evaluates the condition
+	WorldFn func(sensors Sensors, view WorldView) bool // Function that evaluates the condition against the simulated world, takes precedence over CheckFn
 }
 
+// conditionFnCache holds the results of the ConditionFn evaluated during a planning request.
+type conditionFnCache map[*ConditionFn]bool
+
 func (conditionFn *ConditionFn) GetKey() StateKey {
 	return conditionFn.Key
 }
 
 func (conditionFn *ConditionFn) Check(w world) bool {
-	if !conditionFn.resolved {
-		conditionFn.valid = conditionFn.CheckFn(w.Agent.sensors)
-		conditionFn.resolved = true
+	if conditionFn.WorldFn != nil {
+		return conditionFn.WorldFn(w.Agent.sensors, WorldView{w: w})
 	}
 
-	return conditionFn.valid
+	if w.conditionFns == nil {
+		return conditionFn.CheckFn(w.Agent.sensors)
+	}
+
+	valid, ok := w.conditionFns[conditionFn]
+	if !ok {
+		valid = conditionFn.CheckFn(w.Agent.sensors)
+		w.conditionFns[conditionFn] = valid
+	}
+
+	return valid
+}
+
+// WorldView is a read-only access to a world state simulated during planning, given to ConditionFn.WorldFn,
+// or predicted by Plan.Simulate.
+type WorldView struct {
+	w world
+}
+
+// GetStateValue returns the untyped value of a state, and false if it does not exist.
+func (view WorldView) GetStateValue(key StateKey) (any, bool) {
+	state, ok := view.w.getState(key)
+	if !ok {
+		return nil, false
+	}
+
+	return state.GetValue(), true
+}
+
+// HasState returns true if the state exists in the world state.
+func (view WorldView) HasState(key StateKey) bool {
+	return view.w.hasState(key)
+}
+
+// GetStateKeys returns the keys of all the states in the world state.
+func (view WorldView) GetStateKeys() []StateKey {
+	return view.w.getKeys()
+}
+
+// GetViewState returns the value of a state in the world state of view.
+// The boolean is false if the state does not exist, or if its type is not T.
+//
+// Example:
+//
+//	wood, ok := GetViewState[int](view, ATTRIBUTE_WOOD)
+func GetViewState[T Numeric | bool | string](view WorldView, key StateKey) (T, bool) {
+	return lookupState[T](&view.w, key)
+}
+
+// WorldEditor is a writable access to a world state simulated during planning, given to EffectFn.ApplyFn.
+type WorldEditor struct {
+	w *world
+}
+
+// GetStateValue returns the untyped value of a state, and false if it does not exist.
+func (editor WorldEditor) GetStateValue(key StateKey) (any, bool) {
+	state, ok := editor.w.getState(key)
+	if !ok {
+		return nil, false
+	}
+
+	return state.GetValue(), true
+}
+
+// HasState returns true if the state exists in the world state.
+func (editor WorldEditor) HasState(key StateKey) bool {
+	return editor.w.hasState(key)
+}
+
+// DeleteState removes a state from the world state. It returns false if the state does not exist.
+func (editor WorldEditor) DeleteState(key StateKey) bool {
+	return editor.w.deleteState(key)
+}
+
+// GetEditorState returns the value of a state in the world state of editor.
+// The boolean is false if the state does not exist, or if its type is not T.
+func GetEditorState[T Numeric | bool | string](editor WorldEditor, key StateKey) (T, bool) {
+	return lookupState[T](editor.w, key)
+}
+
+// SetEditorState adds or updates a state in the world state of editor.
+// It returns ErrTypeMismatch if the state is registered in the agent's Schema with another type.
+func SetEditorState[T Numeric | bool | string](editor WorldEditor, key StateKey, value T) error {
+	return storeState(editor.w, key, value)
 }
 
 // Condition represents a numeric state-based condition with comparison operators.
@@ -229,6 +350,7 @@ type Condition[T Numeric] struct {
 	Key      StateKey // State key to check
 	Value    T        // Target value to compare against
 	Operator operator // Comparison operator (EQUAL, UPPER, LOWER, etc.)
+	orZero   bool     // Set by the backward search: a missing state is compared as its zero value
 }
 
 func (condition *Condition[T]) GetKey() StateKey {
@@ -236,38 +358,38 @@ func (condition *Condition[T]) GetKey() StateKey {
 }
 
 func (condition *Condition[T]) Check(w world) bool {
-	k := w.states.GetIndex(condition.Key)
-	if k < 0 {
-		return false
+	value, ok := condition.lookup(w)
+
+	return ok && condition.compare(value)
+}
+
+// lookup returns the value of the condition's state in w, and false if the state does not exist or
+// has another type. A missing state has the zero value for the conditions regressed through an ADD or
+// SUBSTRACT effect, as these effects create the missing states from their zero value.
+func (condition *Condition[T]) lookup(w world) (T, bool) {
+	value, ok := lookupState[T](&w, condition.Key)
+	if !ok && condition.orZero && !w.hasState(condition.Key) {
+		return value, true
 	}
-	s := w.states[k]
-	if state, ok := s.(State[T]); ok {
-		switch condition.Operator {
-		case EQUAL:
-			if state.Value == condition.Value {
-				return true
-			}
-		case NOT_EQUAL:
-			if state.Value != condition.Value {
-				return true
-			}
-		case LOWER_OR_EQUAL:
-			if state.Value <= condition.Value {
-				return true
-			}
-		case LOWER:
-			if state.Value < condition.Value {
-				return true
-			}
-		case UPPER_OR_EQUAL:
-			if state.Value >= condition.Value {
-				return true
-			}
-		case UPPER:
-			if state.Value > condition.Value {
-				return true
-			}
-		}
+
+	return value, ok
+}
+
+// compare returns true if value satisfies the condition's operator against its target value.
+func (condition *Condition[T]) compare(value T) bool {
+	switch condition.Operator {
+	case EQUAL:
+		return value == condition.Value
+	case NOT_EQUAL:
+		return value != condition.Value
+	case LOWER_OR_EQUAL:
+		return value <= condition.Value
+	case LOWER:
+		return value < condition.Value
+	case UPPER_OR_EQUAL:
+		return value >= condition.Value
+	case UPPER:
+		return value > condition.Value
 	}
 
 	return false
@@ -297,27 +419,21 @@ func (conditionBool *ConditionBool) GetKey() StateKey {
 }
 
 func (conditionBool *ConditionBool) Check(w world) bool {
-	k := w.states.GetIndex(conditionBool.Key)
-	if k < 0 {
+	value, ok := lookupState[bool](&w, conditionBool.Key)
+
+	return ok && conditionBool.compare(value)
+}
+
+// compare returns true if value satisfies the condition, only EQUAL and NOT_EQUAL are allowed.
+func (conditionBool *ConditionBool) compare(value bool) bool {
+	switch conditionBool.Operator {
+	case EQUAL:
+		return value == conditionBool.Value
+	case NOT_EQUAL:
+		return value != conditionBool.Value
+	default:
 		return false
 	}
-	s := w.states[k]
-	if state, ok := s.(State[bool]); ok {
-		switch conditionBool.Operator {
-		case EQUAL:
-			if state.Value == conditionBool.Value {
-				return true
-			}
-		case NOT_EQUAL:
-			if state.Value != conditionBool.Value {
-				return true
-			}
-		default:
-			return false
-		}
-	}
-
-	return false
 }
 
 // ConditionString represents a string state-based condition.
@@ -345,27 +461,21 @@ func (conditionString *ConditionString) GetKey() StateKey {
 }
 
 func (conditionString *ConditionString) Check(w world) bool {
-	k := w.states.GetIndex(conditionString.Key)
-	if k < 0 {
+	value, ok := lookupState[string](&w, conditionString.Key)
+
+	return ok && conditionString.compare(value)
+}
+
+// compare returns true if value satisfies the condition, only EQUAL and NOT_EQUAL are allowed.
+func (conditionString *ConditionString) compare(value string) bool {
+	switch conditionString.Operator {
+	case EQUAL:
+		return value == conditionString.Value
+	case NOT_EQUAL:
+		return value != conditionString.Value
+	default:
 		return false
 	}
-	s := w.states[k]
-	if state, ok := s.(State[string]); ok {
-		switch conditionString.Operator {
-		case EQUAL:
-			if state.Value == conditionString.Value {
-				return true
-			}
-		case NOT_EQUAL:
-			if state.Value != conditionString.Value {
-				return true
-			}
-		default:
-			return false
-		}
-	}
-
-	return false
 }
 
 // Conditions is a collection of ConditionInterface implementations that must all be satisfied.
@@ -1,6 +1,9 @@
 package goapai
 
-import "testing"
+import (
+	"errors"
+	"testing"
+)
 
 func TestState_Operations(t *testing.T) {
 	tests := []struct {
@@ -210,13 +213,17 @@ func TestConditionFn(t *testing.T) {
 				t.Errorf("Check() = %v, want %v", got, tt.wantResult)
 			}
 
-			// Test caching
-			if !condition.resolved {
-				t.Error("Expected condition to be marked as resolved")
+			// Test caching during a planning request
+			w := agent.w
+			w.conditionFns = conditionFnCache{}
+			condition.Check(w)
+			if _, ok := w.conditionFns[condition]; !ok {
+				t.Error("Expected condition to be cached")
 			}
 
 			// Call again to test cache
-			if got := condition.Check(agent.w); got != tt.wantResult {
+			SetSensor(&agent, "value", tt.threshold-tt.sensorVal)
+			if got := condition.Check(w); got != tt.wantResult {
 				t.Error("Expected cached result to match")
 			}
 		})
@@ -374,3 +381,163 @@ func TestState_Hash(t *testing.T) {
 		})
 	}
 }
+
+func TestWorld_Equal(t *testing.T) {
+	agent1 := CreateAgent(Goals{}, Actions{})
+	State[int]{Key: 1, Value: 100}.Store(&agent1.w)
+	State[string]{Key: 2, Value: "test"}.Store(&agent1.w)
+
+	agent2 := CreateAgent(Goals{}, Actions{})
+	State[string]{Key: 2, Value: "test"}.Store(&agent2.w)
+	State[int]{Key: 1, Value: 100}.Store(&agent2.w)
+
+	if !agent1.w.equal(agent2.w) {
+		t.Error("Expected worlds with the same states in another order to be equal")
+	}
+
+	State[int]{Key: 1, Value: 200}.Store(&agent2.w)
+	if agent1.w.equal(agent2.w) {
+		t.Error("Expected worlds with different values not to be equal")
+	}
+}
+
+func TestState_Hash_WorldDistribution(t *testing.T) {
+	// Setting two bool states to the same value must not produce the same world hash
+	agent1 := CreateAgent(Goals{}, Actions{})
+	State[bool]{Key: 1, Value: true}.Store(&agent1.w)
+	State[bool]{Key: 2, Value: true}.Store(&agent1.w)
+
+	agent2 := CreateAgent(Goals{}, Actions{})
+	State[bool]{Key: 1, Value: false}.Store(&agent2.w)
+	State[bool]{Key: 2, Value: false}.Store(&agent2.w)
+
+	if agent1.w.hash == agent2.w.hash {
+		t.Error("Expected different world hashes")
+	}
+}
+
+func TestConditionFn_CacheResetEachPlan(t *testing.T) {
+	calls := 0
+	actions := Actions{}
+	actions.AddAction("chop_wood", 1.0, false, Conditions{
+		&ConditionFn{
+			Key: 10,
+			CheckFn: func(sensors Sensors) bool {
+				calls++
+				return sensors.GetSensor("has_axe").(bool)
+			},
+		},
+	}, Effects{
+		EffectBool{Key: 1, Value: true, Operator: SET},
+	})
+
+	goals := Goals{
+		"get_wood": {
+			Conditions: Conditions{&ConditionBool{Key: 1, Value: true, Operator: EQUAL}},
+			PriorityFn: func(sensors Sensors) float32 {
+				return 1.0
+			},
+		},
+	}
+
+	agent := CreateAgent(goals, actions)
+	SetState[bool](&agent, 1, false)
+	SetSensor(&agent, "has_axe", false)
+
+	if _, plan := GetPlan(agent, 5); len(plan) != 0 {
+		t.Fatalf("Expected no plan without axe, got %d actions", len(plan))
+	}
+	if calls != 1 {
+		t.Errorf("Expected the condition to be evaluated once per plan, got %d", calls)
+	}
+
+	SetSensor(&agent, "has_axe", true)
+	if _, plan := GetPlan(agent, 5); len(plan) != 2 {
+		t.Errorf("Expected the sensors to be read again by the next plan, got %d actions", len(plan))
+	}
+	if calls != 2 {
+		t.Errorf("Expected the condition to be evaluated once per plan, got %d", calls)
+	}
+}
+
+func TestConditionFn_WorldFn(t *testing.T) {
+	agent := CreateAgent(Goals{}, Actions{})
+	SetState[int](&agent, 1, 5)
+	SetSensor(&agent, "min", 3)
+
+	condition := &ConditionFn{
+		Key: 10,
+		WorldFn: func(sensors Sensors, view WorldView) bool {
+			value, ok := GetViewState[int](view, 1)
+			return ok && value >= sensors.GetSensor("min").(int)
+		},
+	}
+
+	w := agent.w.clone()
+	w.conditionFns = conditionFnCache{}
+	if !condition.Check(w) {
+		t.Error("Expected condition to be valid")
+	}
+
+	// Evaluated against each world, without cache
+	_ = storeState(&w, 1, 2)
+	if condition.Check(w) {
+		t.Error("Expected condition to be invalid in the modified world")
+	}
+	if len(w.conditionFns) != 0 {
+		t.Error("Expected WorldFn not to be cached")
+	}
+
+	view := WorldView{w: w}
+	if value, ok := view.GetStateValue(1); !ok || value != 2 {
+		t.Errorf("GetStateValue() = %v, %v, want 2, true", value, ok)
+	}
+	if view.HasState(2) {
+		t.Error("Expected state 2 not to exist")
+	}
+}
+
+func TestConditionFn_WorldFnPlan(t *testing.T) {
+	actions := Actions{}
+	actions.AddAction("get_wood", 1.0, true, Conditions{}, Effects{
+		Effect[int]{Key: 1, Value: 1, Operator: ADD},
+	})
+	actions.AddAction("make_fire", 1.0, false, Conditions{
+		&ConditionFn{
+			Key: 10,
+			WorldFn: func(sensors Sensors, view WorldView) bool {
+				wood, _ := GetViewState[int](view, 1)
+				return wood >= sensors.GetSensor("wood_per_fire").(int)
+			},
+		},
+	}, Effects{
+		EffectBool{Key: 2, Value: true, Operator: SET},
+	})
+
+	goals := Goals{
+		"stay_warm": {
+			Conditions: Conditions{&ConditionBool{Key: 2, Value: true, Operator: EQUAL}},
+			PriorityFn: func(sensors Sensors) float32 {
+				return 1.0
+			},
+		},
+	}
+
+	agent := CreateAgent(goals, actions)
+	SetState[int](&agent, 1, 0)
+	SetState[bool](&agent, 2, false)
+	SetSensor(&agent, "wood_per_fire", 3)
+
+	_, plan := GetPlan(agent, 10)
+	if len(plan) != 5 {
+		t.Fatalf("Expected plan with 5 actions (root + 4), got %d", len(plan))
+	}
+	if plan[4].name != "make_fire" {
+		t.Errorf("Expected last action to be 'make_fire', got '%s'", plan[4].name)
+	}
+
+	// The backward search cannot regress the WorldFn
+	if _, err := FindPlan(agent, PlanOptions{MaxDepth: 10, Direction: BACKWARD}); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("Expected errors.ErrUnsupported, got %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package goapai
+
+import "fmt"
+
+// ParamCandidatesFn returns the values that the parameter of an action template can be bound to,
+// from the sensors and the world state.
+type ParamCandidatesFn[P comparable] func(sensors Sensors, view WorldView) []P
+
+// actionTemplate binds the parameter of a templated action to its candidate values.
+type actionTemplate struct {
+	bind func(w world, bindings actionBindings) Actions
+}
+
+// actionBinding identifies an action bound from a template.
+type actionBinding struct {
+	template *Action
+	param    any
+}
+
+// actionBindings holds the actions bound during a planning request, so that each binding
+// is built only once.
+type actionBindings map[actionBinding]*Action
+
+// AddActionTemplate creates an action template with a parameter of type P, and appends it to the Actions collection.
+//
+// Instead of one action per value (e.g. "pick up" for each item), the template is bound by the planner
+// to the values returned by candidatesFn. For each value, buildFn returns the conditions and effects of the
+// bound action, that can reference the value. The bound actions are named "name(value)", and their
+// value is returned by GetParam.
+//
+// The forward search binds the template during expansion, against each simulated world state. The backward
+// search binds it once, against the current world state. A non-repeatable template can be used once per value.
+// The executor and the cost function of the template are shared by the bound actions.
+//
+// Example:
+//
+//	goapai.AddActionTemplate(&actions, "pick_up", 1, false,
+//	    func(sensors goapai.Sensors, view goapai.WorldView) []goapai.StateKey {
+//	        return sensors.GetSensor("items").([]goapai.StateKey)
+//	    },
+//	    func(item goapai.StateKey) (goapai.Conditions, goapai.Effects) {
+//	        return goapai.Conditions{}, goapai.Effects{goapai.EffectBool{Key: item, Value: true, Operator: goapai.SET}}
+//	    },
+//	)
+func AddActionTemplate[P comparable](actions *Actions, name string, cost float32, repeatable bool, candidatesFn ParamCandidatesFn[P], buildFn func(param P) (Conditions, Effects)) {
+	template := &Action{
+		name:       name,
+		cost:       cost,
+		repeatable: repeatable,
+	}
+
+	template.template = &actionTemplate{
+		bind: func(w world, bindings actionBindings) Actions {
+			candidates := candidatesFn(w.Agent.sensors, WorldView{w: w})
+			bound := make(Actions, 0, len(candidates))
+
+			for _, param := range candidates {
+				binding := actionBinding{template: template, param: param}
+				if action, ok := bindings[binding]; ok {
+					bound = append(bound, action)
+					continue
+				}
+
+				conditions, effects := buildFn(param)
+				action := &Action{
+					name:       fmt.Sprintf("%s(%v)", name, param),
+					cost:       template.cost,
+					repeatable: template.repeatable,
+					conditions: conditions,
+					effects:    effects,
+					executor:   template.executor,
+					costFn:     template.costFn,
+					param:      param,
+				}
+				if bindings != nil {
+					bindings[binding] = action
+				}
+				bound = append(bound, action)
+			}
+
+			return bound
+		},
+	}
+
+	*actions = append(*actions, template)
+}
+
+// GetParam returns the value bound to the parameter of an action created from a template,
+// or nil for the other actions.
+func (action *Action) GetParam() any {
+	return action.param
+}
+
+// IsTemplate returns true if the action is a template, bound by the planner to the values of its parameter.
+func (action *Action) IsTemplate() bool {
+	return action.template != nil
+}
+
+// hasTemplates returns true if at least one of the actions is a template.
+func (actions Actions) hasTemplates() bool {
+	for _, action := range actions {
+		if action.template != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// bindActions returns the actions, with each template replaced by its actions bound in w.
+func bindActions(w world, actions Actions, bindings actionBindings) Actions {
+	bound := make(Actions, 0, len(actions))
+
+	for _, action := range actions {
+		if action.template == nil {
+			bound = append(bound, action)
+			continue
+		}
+
+		for _, boundAction := range action.template.bind(w, bindings) {
+			if !boundAction.effects.satisfyStates(w) {
+				bound = append(bound, boundAction)
+			}
+		}
+	}
+
+	return bound
+}
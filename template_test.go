@@ -0,0 +1,136 @@
+package goapai
+
+import "testing"
+
+// createTemplateAgent creates an agent picking up items, listed in its "items" sensor.
+// The goal requires the items 11 and 13.
+func createTemplateAgent() (*Agent, Actions) {
+	actions := Actions{}
+	AddActionTemplate(&actions, "pick_up", 1.0, false,
+		func(sensors Sensors, view WorldView) []StateKey {
+			return sensors.GetSensor("items").([]StateKey)
+		},
+		func(item StateKey) (Conditions, Effects) {
+			return Conditions{
+				&ConditionBool{Key: 1, Value: true, Operator: EQUAL},
+			}, Effects{
+				EffectBool{Key: item, Value: true, Operator: SET},
+			}
+		},
+	)
+	actions.AddAction("open_bag", 1.0, false, Conditions{}, Effects{
+		EffectBool{Key: 1, Value: true, Operator: SET},
+	})
+
+	goals := Goals{
+		"collect": {
+			Conditions: Conditions{
+				&ConditionBool{Key: 11, Value: true, Operator: EQUAL},
+				&ConditionBool{Key: 13, Value: true, Operator: EQUAL},
+			},
+			PriorityFn: func(sensors Sensors) float32 {
+				return 1.0
+			},
+		},
+	}
+
+	agent := CreateAgent(goals, actions)
+	SetState[bool](&agent, 1, false)
+	SetSensor(&agent, "items", []StateKey{10, 11, 12, 13})
+
+	return &agent, actions
+}
+
+func TestAddActionTemplate(t *testing.T) {
+	agent, actions := createTemplateAgent()
+
+	template := actions.GetAction("pick_up")
+	if template == nil || !template.IsTemplate() {
+		t.Fatal("Expected 'pick_up' to be a template")
+	}
+	if actions.GetAction("open_bag").IsTemplate() {
+		t.Error("Expected 'open_bag' not to be a template")
+	}
+
+	bindings := actionBindings{}
+	bound := template.template.bind(agent.w, bindings)
+	if len(bound) != 4 {
+		t.Fatalf("Expected 4 bound actions, got %d", len(bound))
+	}
+	if bound[1].GetName() != "pick_up(11)" || bound[1].GetParam() != StateKey(11) {
+		t.Errorf("Expected 'pick_up(11)' bound to 11, got '%s' bound to %v", bound[1].GetName(), bound[1].GetParam())
+	}
+	if len(bound[1].GetEffects()) != 1 || bound[1].GetEffects()[0].GetKey() != 11 {
+		t.Error("Expected the effects to reference the parameter")
+	}
+
+	// Each binding is built once per planning request
+	if again := template.template.bind(agent.w, bindings); again[1] != bound[1] {
+		t.Error("Expected the bound action to be reused")
+	}
+	if again := template.template.bind(agent.w, nil); again[1] == bound[1] {
+		t.Error("Expected a new bound action without cache")
+	}
+}
+
+func TestAddActionTemplate_Plan(t *testing.T) {
+	agent, _ := createTemplateAgent()
+
+	for _, direction := range []direction{FORWARD, BACKWARD} {
+		result, err := FindPlan(*agent, PlanOptions{MaxDepth: 10, Direction: direction})
+		if err != nil {
+			t.Fatalf("Direction %d: unexpected error: %v", direction, err)
+		}
+		if len(result.Plan) != 4 {
+			t.Fatalf("Direction %d: expected plan with 4 actions (root + 3), got %d", direction, len(result.Plan))
+		}
+
+		params := map[any]bool{}
+		for _, action := range result.Plan[2:] {
+			params[action.GetParam()] = true
+		}
+		if result.Plan[1].GetName() != "open_bag" || !params[StateKey(11)] || !params[StateKey(13)] {
+			t.Errorf("Direction %d: expected plan [open_bag, pick_up(11), pick_up(13)], got %s, %s, %s", direction,
+				result.Plan[1].GetName(), result.Plan[2].GetName(), result.Plan[3].GetName())
+		}
+	}
+}
+
+func TestAddActionTemplate_CandidatesFromWorld(t *testing.T) {
+	actions := Actions{}
+	// Moving is only possible to the neighbours of the current position
+	AddActionTemplate(&actions, "move", 1.0, true,
+		func(sensors Sensors, view WorldView) []int {
+			position, _ := GetViewState[int](view, 1)
+			return []int{position - 1, position + 1}
+		},
+		func(position int) (Conditions, Effects) {
+			return Conditions{}, Effects{Effect[int]{Key: 1, Value: position, Operator: SET}}
+		},
+	)
+
+	goals := Goals{
+		"go_to_3": {
+			Conditions: Conditions{&Condition[int]{Key: 1, Value: 3, Operator: EQUAL}},
+			PriorityFn: func(sensors Sensors) float32 {
+				return 1.0
+			},
+		},
+	}
+
+	agent := CreateAgent(goals, actions)
+	SetState[int](&agent, 1, 0)
+
+	result, err := FindPlan(agent, PlanOptions{MaxDepth: 10})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.Plan) != 4 {
+		t.Fatalf("Expected plan with 4 actions (root + 3), got %d", len(result.Plan))
+	}
+	for i, action := range result.Plan[1:] {
+		if action.GetParam() != i+1 {
+			t.Errorf("Expected step %d to move to %d, got %v", i+1, i+1, action.GetParam())
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package goapai
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type traceStatus uint8
+
+const (
+	// NODE_OPEN is a node generated but never expanded, as the search ended before.
+	NODE_OPEN traceStatus = iota
+	// NODE_CLOSED is a node expanded by the search.
+	NODE_CLOSED
+	// NODE_GOAL is the node matching the goal, at the end of the plan.
+	NODE_GOAL
+	// REJECTED_DEPTH is a node not expanded, as it is beyond the depth limit.
+	REJECTED_DEPTH
+	// REJECTED_CONDITIONS is an action whose conditions do not hold in the parent node.
+	REJECTED_CONDITIONS
+	// REJECTED_NO_EFFECT is an action whose effects are already satisfied in the parent node.
+	REJECTED_NO_EFFECT
+	// REJECTED_REPETITION is a non-repeatable action already performed on the path to the parent node.
+	REJECTED_REPETITION
+	// REJECTED_EFFECT_ERROR is an action whose effects could not be applied to the parent node.
+	REJECTED_EFFECT_ERROR
+	// REJECTED_DUPLICATE is an action leading to the world state of another node, either already
+	// expanded, or still open with a cost lower or equal to the cost through the action.
+	REJECTED_DUPLICATE
+)
+
+var traceStatusNames = [...]string{
+	"open",
+	"closed",
+	"goal",
+	"depth limit",
+	"conditions failed",
+	"no effect",
+	"not repeatable",
+	"effect error",
+	"duplicate",
+}
+
+func (status traceStatus) String() string {
+	return traceStatusNames[status]
+}
+
+// MarshalText encodes the status as its name in the JSON export.
+func (status traceStatus) MarshalText() ([]byte, error) {
+	return []byte(status.String()), nil
+}
+
+// SearchTrace records the nodes explored by the forward searches of a planning request, for debugging.
+//
+// It is enabled by setting PlanOptions.Trace, and can then be exported with WriteDOT and WriteJSON.
+// Each node holds the changes of the world state from its parent node. The actions not leading to a node
+// from an expanded node are recorded as rejected nodes, with the reason of their rejection. Recording
+// slows the search down and keeps all the nodes in memory: it is meant for debugging only. The
+// backward searches and the decompositions of HTN tasks are not recorded, but the GOAP plans of
+// their goal tasks are. A SearchTrace must not be shared by concurrent planning requests.
+//
+// Example:
+//
+//	trace := &goapai.SearchTrace{}
+//	result, err := goapai.FindPlan(agent, goapai.PlanOptions{MaxDepth: 10, Trace: trace})
+//	file, _ := os.Create("search.dot")
+//	trace.WriteDOT(file)
+type SearchTrace struct {
+	Nodes []TraceNode
+
+	goal   GoalName
+	schema *Schema
+}
+
+// TraceNode is a node of a SearchTrace.
+type TraceNode struct {
+	ID        int          `json:"id"`
+	Parent    int          `json:"parent"` // -1 for the starting node of a search
+	Goal      GoalName     `json:"goal"`
+	Action    string       `json:"action,omitempty"` // Empty for the starting node of a search
+	Cost      float32      `json:"cost"`             // Cost of the path from the starting node
+	Heuristic float32      `json:"heuristic"`        // 0 for the rejected nodes
+	Depth     int          `json:"depth"`
+	Changes   []TraceState `json:"changes,omitempty"` // States modified by the action
+	Status    traceStatus  `json:"status"`
+	Reason    string       `json:"reason,omitempty"` // Details of the rejection
+
+	FailedConditions []string `json:"failedConditions,omitempty"` // Conditions of a REJECTED_CONDITIONS action
+}
+
+// TraceState is a state modified by the action of a TraceNode.
+type TraceState struct {
+	Key     StateKey `json:"key"`
+	Name    string   `json:"name,omitempty"` // Name registered in the Schema, if any
+	Value   any      `json:"value,omitempty"`
+	Deleted bool     `json:"deleted,omitempty"`
+}
+
+// WriteJSON writes the nodes of the trace as a JSON array.
+func (trace *SearchTrace) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	nodes := trace.Nodes
+	if nodes == nil {
+		nodes = []TraceNode{}
+	}
+
+	return encoder.Encode(nodes)
+}
+
+// WriteDOT writes the trace as a Graphviz graph, with a cluster per goal.
+// The nodes of the plan are green, the rejected ones are grey and dashed, and the open ones are blue.
+func (trace *SearchTrace) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+
+	b.WriteString("digraph search {\n")
+	b.WriteString("  node [shape=box, fontname=\"monospace\", fontsize=10];\n")
+
+	var goals []GoalName
+	for _, n := range trace.Nodes {
+		if !slices.Contains(goals, n.Goal) {
+			goals = append(goals, n.Goal)
+		}
+	}
+
+	inPlan := trace.planNodes()
+	for i, goal := range goals {
+		fmt.Fprintf(&b, "  subgraph cluster_%d {\n    label=%s;\n", i, strconv.Quote(string(goal)))
+		for _, n := range trace.Nodes {
+			if n.Goal != goal {
+				continue
+			}
+
+			style := ""
+			switch {
+			case inPlan[n.ID]:
+				style = ", color=green, penwidth=2"
+			case n.Status >= REJECTED_DEPTH:
+				style = ", color=grey, fontcolor=grey, style=dashed"
+			case n.Status == NODE_OPEN:
+				style = ", color=blue"
+			}
+			fmt.Fprintf(&b, "    n%d [label=%s%s];\n", n.ID, strconv.Quote(n.label()), style)
+		}
+		b.WriteString("  }\n")
+	}
+
+	for _, n := range trace.Nodes {
+		if n.Parent >= 0 {
+			fmt.Fprintf(&b, "  n%d -> n%d;\n", n.Parent, n.ID)
+		}
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+// planNodes returns the IDs of the nodes on the path to each goal node.
+func (trace *SearchTrace) planNodes() map[int]bool {
+	inPlan := map[int]bool{}
+	for _, n := range trace.Nodes {
+		if n.Status != NODE_GOAL {
+			continue
+		}
+		for id := n.ID; id >= 0; id = trace.Nodes[id].Parent {
+			inPlan[id] = true
+		}
+	}
+
+	return inPlan
+}
+
+func (n TraceNode) label() string {
+	action := n.Action
+	if n.Parent < 0 {
+		action = "start"
+	}
+
+	lines := []string{
+		action,
+		fmt.Sprintf("g=%g h=%g depth=%d", n.Cost, n.Heuristic, n.Depth),
+	}
+	for _, state := range n.Changes {
+		lines = append(lines, state.String())
+	}
+	if n.Status != NODE_CLOSED {
+		lines = append(lines, "["+n.Status.String()+"]")
+	}
+	if n.Reason != "" {
+		lines = append(lines, n.Reason)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (state TraceState) String() string {
+	name := strconv.Itoa(int(state.Key))
+	if state.Name != "" {
+		name = state.Name
+	}
+	if state.Deleted {
+		return name + " deleted"
+	}
+
+	return fmt.Sprintf("%s = %v", name, state.Value)
+}
+
+// startGoal sets the goal of the next searches.
+func (trace *SearchTrace) startGoal(goal GoalName) {
+	if trace != nil {
+		trace.goal = goal
+	}
+}
+
+// addNode records a node generated by the search, and sets its traceID.
+func (trace *SearchTrace) addNode(n *node) {
+	if trace == nil {
+		return
+	}
+
+	trace.schema = n.world.schema()
+	n.traceID = len(trace.Nodes)
+	trace.Nodes = append(trace.Nodes, TraceNode{ID: n.traceID, Goal: trace.goal})
+	trace.updateNode(n)
+}
+
+// updateNode records the path of a node whose cost was lowered by another parent.
+func (trace *SearchTrace) updateNode(n *node) {
+	if trace == nil {
+		return
+	}
+
+	traceNode := &trace.Nodes[n.traceID]
+	traceNode.Parent = -1
+	traceNode.Action = ""
+	traceNode.Changes = nil
+	if n.parentNode != nil {
+		traceNode.Parent = n.parentNode.traceID
+		traceNode.Action = n.Action.name
+		traceNode.Changes = trace.changes(n.parentNode.world, n.world)
+	}
+	traceNode.Cost = n.cost
+	traceNode.Heuristic = n.heuristic
+	traceNode.Depth = int(n.depth)
+}
+
+// setStatus records the status of a node, once popped from the open set.
+func (trace *SearchTrace) setStatus(n *node, status traceStatus) {
+	if trace != nil {
+		trace.Nodes[n.traceID].Status = status
+	}
+}
+
+// reject records an action not leading to a new node from parentNode.
+func (trace *SearchTrace) reject(parentNode *node, action *Action, status traceStatus, reason string) {
+	if trace == nil {
+		return
+	}
+
+	trace.Nodes = append(trace.Nodes, TraceNode{
+		ID:     len(trace.Nodes),
+		Parent: parentNode.traceID,
+		Goal:   trace.goal,
+		Action: action.name,
+		Cost:   parentNode.cost + action.getCost(parentNode.world),
+		Depth:  int(parentNode.depth) + 1,
+		Status: status,
+		Reason: reason,
+	})
+}
+
+// rejectConditions records an action whose conditions do not hold in parentNode.
+func (trace *SearchTrace) rejectConditions(parentNode *node, action *Action) {
+	if trace == nil {
+		return
+	}
+
+	failed := failedConditions(action.conditions, parentNode.world)
+	trace.reject(parentNode, action, REJECTED_CONDITIONS, strings.Join(failed, ", "))
+	trace.Nodes[len(trace.Nodes)-1].FailedConditions = failed
+}
+
+// changes returns the states of to that are different in from.
+func (trace *SearchTrace) changes(from world, to world) []TraceState {
+	var changes []TraceState
+
+	for _, key := range to.getKeys() {
+		state, _ := to.getState(key)
+		if previous, ok := from.getState(key); ok && previous.GetValue() == state.GetValue() {
+			continue
+		}
+		changes = append(changes, trace.traceState(key, state.GetValue(), false))
+	}
+	for _, key := range from.getKeys() {
+		if !to.hasState(key) {
+			changes = append(changes, trace.traceState(key, nil, true))
+		}
+	}
+
+	slices.SortFunc(changes, func(a, b TraceState) int { return int(a.Key) - int(b.Key) })
+
+	return changes
+}
+
+func (trace *SearchTrace) traceState(key StateKey, value any, deleted bool) TraceState {
+	name, _ := trace.schema.GetName(key)
+
+	return TraceState{Key: key, Name: name, Value: value, Deleted: deleted}
+}
+
+// failedConditions describes the conditions that do not hold in w.
+func failedConditions(conditions Conditions, w world) []string {
+	var failed []string
+	for _, condition := range conditions {
+		if !condition.Check(w) {
+			failed = append(failed, describeCondition(condition, w.schema()))
+		}
+	}
+
+	return failed
+}
+
+// describedCondition is implemented by the conditions comparing a state with a value.
+type describedCondition interface {
+	ConditionInterface
+	operands() (operator, any)
+}
+
+// describeCondition returns a readable form of the condition, e.g. "wood >= 5".
+func describeCondition(condition ConditionInterface, schema *Schema) string {
+	key := strconv.Itoa(int(condition.GetKey()))
+	if name, ok := schema.GetName(condition.GetKey()); ok {
+		key = name
+	}
+
+	switch condition := condition.(type) {
+	case describedCondition:
+		operator, value := condition.operands()
+		return fmt.Sprintf("%s %s %v", key, operator, value)
+	case *ConditionFn:
+		return fmt.Sprintf("fn(%s)", key)
+	}
+
+	return fmt.Sprintf("condition on %s", key)
+}
@@ -0,0 +1,198 @@
+package goapai
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// createTraceAgent creates an agent that needs an axe to chop wood, with a schema naming the states.
+func createTraceAgent(t *testing.T) Agent {
+	schema := CreateSchema()
+	if err := RegisterNamedState[bool](schema, 1, "has_axe"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterNamedState[int](schema, 2, "wood"); err != nil {
+		t.Fatal(err)
+	}
+
+	actions := Actions{}
+	actions.AddAction("chop_wood", 1, true, Conditions{
+		&ConditionBool{Key: 1, Value: true, Operator: EQUAL},
+	}, Effects{
+		Effect[int]{Key: 2, Value: 1, Operator: ADD},
+	})
+	actions.AddAction("take_axe", 1, false, Conditions{}, Effects{
+		EffectBool{Key: 1, Value: true, Operator: SET},
+	})
+
+	goals := Goals{
+		"gather_wood": {
+			Conditions: Conditions{&Condition[int]{Key: 2, Value: 2, Operator: UPPER_OR_EQUAL}},
+			PriorityFn: func(sensors Sensors) float32 { return 1 },
+		},
+	}
+
+	agent, err := CreateAgentWithSchema(goals, actions, schema)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	SetState[bool](&agent, 1, false)
+	SetState[int](&agent, 2, 0)
+
+	return agent
+}
+
+func TestSearchTrace(t *testing.T) {
+	agent := createTraceAgent(t)
+	trace := &SearchTrace{}
+
+	result, err := FindPlan(agent, PlanOptions{MaxDepth: 5, Trace: trace})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	statuses := map[traceStatus]int{}
+	var goalNode TraceNode
+	for i, n := range trace.Nodes {
+		if n.ID != i {
+			t.Errorf("Expected node %d to have the ID %d, got %d", i, i, n.ID)
+		}
+		if n.Goal != "gather_wood" {
+			t.Errorf("Expected the goal gather_wood, got %s", n.Goal)
+		}
+		statuses[n.Status]++
+		if n.Status == NODE_GOAL {
+			goalNode = n
+		}
+	}
+
+	if statuses[NODE_GOAL] != 1 {
+		t.Fatalf("Expected one goal node, got %d", statuses[NODE_GOAL])
+	}
+	if goalNode.Depth != len(result.Plan)-1 || goalNode.Cost != result.Plan.GetTotalCost() {
+		t.Errorf("Expected the goal node at depth %d with cost %v, got %d and %v",
+			len(result.Plan)-1, result.Plan.GetTotalCost(), goalNode.Depth, goalNode.Cost)
+	}
+	if len(goalNode.Changes) != 1 || goalNode.Changes[0].Name != "wood" || goalNode.Changes[0].Value != 2 {
+		t.Errorf("Expected the goal node to change wood to 2, got %v", goalNode.Changes)
+	}
+
+	// The path to the goal node is the plan
+	for id, i := goalNode.ID, len(result.Plan)-1; i > 0; id, i = trace.Nodes[id].Parent, i-1 {
+		if trace.Nodes[id].Action != result.Plan[i].GetName() {
+			t.Errorf("Expected the action %s at depth %d, got %s", result.Plan[i].GetName(), i, trace.Nodes[id].Action)
+		}
+	}
+
+	// From the start, chop_wood fails without the axe, and take_axe cannot be repeated afterwards
+	var conditionsReason string
+	for _, n := range trace.Nodes {
+		if n.Status == REJECTED_CONDITIONS {
+			conditionsReason = n.Reason
+		}
+	}
+	if conditionsReason != "has_axe == true" {
+		t.Errorf("Expected the rejection reason \"has_axe == true\", got %q", conditionsReason)
+	}
+	if statuses[REJECTED_REPETITION] == 0 {
+		t.Error("Expected take_axe to be rejected as not repeatable")
+	}
+}
+
+func TestSearchTrace_Duplicate(t *testing.T) {
+	actions := Actions{}
+	actions.AddAction("step_up", 1, true, Conditions{}, Effects{Effect[int]{Key: 1, Value: 1, Operator: ADD}})
+	actions.AddAction("climb", 2, true, Conditions{}, Effects{Effect[int]{Key: 1, Value: 1, Operator: ADD}})
+	actions.AddAction("step_down", 1, true, Conditions{}, Effects{Effect[int]{Key: 1, Value: 1, Operator: SUBSTRACT}})
+
+	goals := Goals{
+		"reach": {
+			Conditions: Conditions{&Condition[int]{Key: 1, Value: 2, Operator: UPPER_OR_EQUAL}},
+			PriorityFn: func(sensors Sensors) float32 { return 1 },
+		},
+	}
+
+	agent := CreateAgent(goals, actions)
+	SetState[int](&agent, 1, 0)
+
+	trace := &SearchTrace{}
+	if _, err := FindPlan(agent, PlanOptions{MaxDepth: 5, Trace: trace}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// step_down leads back to an expanded node, and climb to an open node reached by step_up at a lower cost
+	reasons := map[string]string{}
+	for _, n := range trace.Nodes {
+		if n.Status == REJECTED_DUPLICATE {
+			reasons[n.Action] = n.Reason[strings.Index(n.Reason, ",")+2:]
+		}
+	}
+	if reasons["step_down"] != "already expanded" || reasons["climb"] != "with no better cost" {
+		t.Errorf("Expected duplicates of expanded and open nodes, got %v", reasons)
+	}
+}
+
+func TestSearchTrace_DepthLimit(t *testing.T) {
+	agent := createTraceAgent(t)
+	trace := &SearchTrace{}
+
+	if _, err := FindPlan(agent, PlanOptions{MaxDepth: 2, Trace: trace}); err == nil {
+		t.Fatal("Expected an error")
+	}
+
+	var depthRejected int
+	for _, n := range trace.Nodes {
+		if n.Status == REJECTED_DEPTH {
+			depthRejected++
+			if n.Depth != 3 {
+				t.Errorf("Expected the nodes beyond the depth limit at depth 3, got %d", n.Depth)
+			}
+		}
+	}
+	if depthRejected == 0 {
+		t.Error("Expected nodes rejected by the depth limit")
+	}
+}
+
+func TestSearchTrace_Export(t *testing.T) {
+	agent := createTraceAgent(t)
+	trace := &SearchTrace{}
+
+	if _, err := FindPlan(agent, PlanOptions{MaxDepth: 5, Trace: trace}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var buffer bytes.Buffer
+	if err := trace.WriteJSON(&buffer); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var nodes []map[string]any
+	if err := json.Unmarshal(buffer.Bytes(), &nodes); err != nil {
+		t.Fatalf("Expected valid JSON, got %v", err)
+	}
+	if len(nodes) != len(trace.Nodes) {
+		t.Errorf("Expected %d nodes, got %d", len(trace.Nodes), len(nodes))
+	}
+	if nodes[0]["status"] != "closed" || nodes[0]["parent"] != float64(-1) {
+		t.Errorf("Expected a closed starting node, got %v", nodes[0])
+	}
+
+	buffer.Reset()
+	if err := trace.WriteDOT(&buffer); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	dot := buffer.String()
+	if !strings.HasPrefix(dot, "digraph search {") || !strings.HasSuffix(dot, "}\n") {
+		t.Errorf("Expected a digraph, got %s", dot)
+	}
+	if edges := strings.Count(dot, " -> "); edges != len(trace.Nodes)-1 {
+		t.Errorf("Expected %d edges, got %d", len(trace.Nodes)-1, edges)
+	}
+	if !strings.Contains(dot, `label="gather_wood"`) || !strings.Contains(dot, `wood = 2`) {
+		t.Errorf("Expected the goal cluster and the state names, got %s", dot)
+	}
+}